@@ -66,7 +66,7 @@ var onsuccess = function(request) {
 
   scaleboxes()
 };
-setInterval(function() {
+var refresh = function() {
   var request = new XMLHttpRequest();
   request.open('GET', location.href, true);
   request.onload = function() {
@@ -78,12 +78,47 @@ setInterval(function() {
   };
   request.onerror = onerror;
   request.send();
+};
+
+// While the event stream is open tiles are patched as they change and polling is
+// paused, polling resumes whenever the stream drops
+var live = false;
+var replaceTile = function(event) {
+  var tile = JSON.parse(event.data);
+  var tiles = document.querySelectorAll('[data-key]');
+  for (var i = 0; i < tiles.length; i++) {
+    if (tiles[i].getAttribute('data-key') == tile.key) {
+      var holder = document.createElement('div');
+      holder.innerHTML = tile.html;
+      tiles[i].parentNode.replaceChild(holder.querySelector('[data-key]'), tiles[i]);
+      scaleboxes();
+      return;
+    }
+  }
+  refresh();
+};
+if (window.EventSource && /^\/(host|group)\/[^\/]+\/?$/.test(location.pathname)) {
+  var source = new EventSource(location.pathname.replace(/\/$/, '') + '/events');
+  source.addEventListener('open', function() { live = true; });
+  source.addEventListener('error', function() { live = false; });
+  source.addEventListener('tile', replaceTile);
+  source.addEventListener('refresh', function() { refresh(); });
+}
+
+setInterval(function() {
+  if (!live) {
+    refresh();
+  }
 }, refresh_interval * 1000);
 setInterval(function() {
   var el = document.getElementById('countdown');
   if(el) {
+    if (live) {
+      el.innerText = 'live';
+      return;
+    }
     var counter = parseInt(el.innerText, 10);
-    el.innerText = counter - 1;
+    el.innerText = isNaN(counter) ? refresh_interval : counter - 1;
   }
 }, 1000);
 
@@ -0,0 +1,242 @@
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// apiVersion is served with every API response, fields are only ever added
+// within a version
+const apiVersion = "v1"
+
+type apiError struct {
+	Version  string   `json:"version"`
+	Error    string   `json:"error"`
+	Problems []string `json:"problems,omitempty"`
+}
+
+type apiHosts struct {
+	Version string    `json:"version"`
+	Hosts   []apiHost `json:"hosts"`
+}
+
+type apiGroup struct {
+	Version string    `json:"version"`
+	Group   string    `json:"group"`
+	Hosts   []apiHost `json:"hosts"`
+}
+
+type apiHostResponse struct {
+	Version string `json:"version"`
+	apiHost
+}
+
+type apiHost struct {
+	Host        string     `json:"host"`
+	Error       string     `json:"error,omitempty"`
+	FetchedAt   *time.Time `json:"fetched_at"`
+	AttemptedAt *time.Time `json:"attempted_at"`
+	Pipelines   []apiData  `json:"pipelines"`
+}
+
+type apiData struct {
+	Data
+	Percentages map[string]int `json:"percentages"`
+	Since       *time.Time     `json:"since"`
+	LastGreen   *time.Time     `json:"last_green"`
+}
+
+type apiHostReport struct {
+	Version string `json:"version"`
+	reportHost
+}
+
+type apiGroupReport struct {
+	Version string       `json:"version"`
+	Group   string       `json:"group"`
+	Hosts   []reportHost `json:"hosts"`
+}
+
+type apiJobs struct {
+	Version  string   `json:"version"`
+	Host     string   `json:"host"`
+	Pipeline string   `json:"pipeline"`
+	Jobs     []apiJob `json:"jobs"`
+}
+
+type apiJob struct {
+	Team           string     `json:"team"`
+	Pipeline       string     `json:"pipeline"`
+	Name           string     `json:"name"`
+	URL            string     `json:"url"`
+	BuildURL       string     `json:"build_url"`
+	Status         string     `json:"status"`
+	Running        bool       `json:"running"`
+	Paused         bool       `json:"paused"`
+	LatestBuildNum string     `json:"latest_build"`
+	StartTime      *time.Time `json:"start_time"`
+	EndTime        *time.Time `json:"end_time"`
+}
+
+func newAPIHost(groupData GroupData) apiHost {
+	host := apiHost{
+		Host:        groupData.Host,
+		Error:       groupData.Error,
+		FetchedAt:   timestamp(groupData.LastFetched),
+		AttemptedAt: timestamp(groupData.LastAttempted),
+		Pipelines:   []apiData{},
+	}
+	for _, datum := range groupData.Statuses {
+		percentages := map[string]int{}
+		for status := range datum.Statuses {
+			percentages[status] = datum.Percent(status)
+		}
+		host.Pipelines = append(host.Pipelines, apiData{
+			Data:        datum,
+			Percentages: percentages,
+			Since:       timestamp(datum.Since),
+			LastGreen:   timestamp(datum.LastGreen),
+		})
+	}
+	return host
+}
+
+func newAPIJob(job JobData) apiJob {
+	return apiJob{
+		Team:           job.Team,
+		Pipeline:       job.Pipeline,
+		Name:           job.Name,
+		URL:            job.URL,
+		BuildURL:       job.BuildURL,
+		Status:         job.Status,
+		Running:        job.Running,
+		Paused:         job.Paused,
+		LatestBuildNum: job.LatestBuildNum,
+		StartTime:      timestamp(job.StartTime),
+		EndTime:        timestamp(job.EndTime),
+	}
+}
+
+// timestamp returns nil for the zero time so unknown times are served as null,
+// times are served in UTC
+func timestamp(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	t = t.UTC()
+	return &t
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+// APIHosts serves the data of every configured host as JSON
+func (config *Config) APIHosts(w http.ResponseWriter, r *http.Request) {
+	hosts := []apiHost{}
+	for _, groupData := range config.groupData(CSGroup{Hosts: config.Hosts}) {
+		hosts = append(hosts, newAPIHost(groupData))
+	}
+	writeJSON(w, http.StatusOK, apiHosts{Version: apiVersion, Hosts: hosts})
+}
+
+// APIHostSummary serves the data of a single host as JSON
+func (config *Config) APIHostSummary(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+
+	groupData := config.groupData(CSGroup{Hosts: []Host{{FQDN: host}}})[0]
+	status := http.StatusOK
+	if groupData.Error != "" {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, apiHostResponse{Version: apiVersion, apiHost: newAPIHost(groupData)})
+}
+
+// APIGroupSummary serves the data of a concourse summary group as JSON, hosts which
+// fail are included with their error
+func (config *Config) APIGroupSummary(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+
+	csGroup := config.CSGroups.group(group)
+	if csGroup.Group == "" {
+		writeJSON(w, http.StatusNotFound, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) not found", group)})
+		return
+	}
+
+	hosts := []apiHost{}
+	for _, groupData := range config.groupData(csGroup) {
+		hosts = append(hosts, newAPIHost(groupData))
+	}
+	writeJSON(w, http.StatusOK, apiGroup{Version: apiVersion, Group: group, Hosts: hosts})
+}
+
+// APIJobsSummary serves the jobs of a single pipeline as JSON
+func (config *Config) APIJobsSummary(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	host := vars["host"]
+	pipeline := vars["pipeline"]
+
+	snapshot := config.hostSnapshot(host)
+	if snapshot.Err != nil {
+		fmt.Println(snapshot.Err.Error())
+		writeJSON(w, http.StatusInternalServerError, apiError{Version: apiVersion, Error: errorClass(snapshot.Err)})
+		return
+	}
+
+	jobs, found := pipelineJobs(snapshot.Data, r.URL.Query().Get("team"), pipeline, r.URL.Query().Get("group"))
+	if !found {
+		writeJSON(w, http.StatusNotFound, apiError{Version: apiVersion, Error: fmt.Sprintf("pipeline (%s) not found on concourse (%s)", pipeline, host)})
+		return
+	}
+
+	response := apiJobs{Version: apiVersion, Host: host, Pipeline: pipeline, Jobs: []apiJob{}}
+	for _, job := range jobs {
+		response.Jobs = append(response.Jobs, newAPIJob(job))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+// APIHostReport serves the reliability report of a host as JSON
+func (config *Config) APIHostReport(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+
+	snapshot := config.hostSnapshot(host)
+	if snapshot.Err != nil {
+		fmt.Println(snapshot.Err.Error())
+		writeJSON(w, http.StatusInternalServerError, apiError{Version: apiVersion, Error: errorClass(snapshot.Err)})
+		return
+	}
+
+	report := config.hostReport(host, snapshot.Data, time.Now())
+	status := http.StatusOK
+	if report.Error != "" {
+		status = http.StatusInternalServerError
+	}
+	writeJSON(w, status, apiHostReport{Version: apiVersion, reportHost: report})
+}
+
+// APIGroupReport serves the reliability report of a concourse summary group as JSON,
+// hosts which fail are included with their error
+func (config *Config) APIGroupReport(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+
+	csGroup := config.CSGroups.group(group)
+	if csGroup.Group == "" {
+		writeJSON(w, http.StatusNotFound, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) not found", group)})
+		return
+	}
+
+	hosts := config.groupReport(csGroup, time.Now())
+	if hosts == nil {
+		hosts = []reportHost{}
+	}
+	writeJSON(w, http.StatusOK, apiGroupReport{Version: apiVersion, Group: group, Hosts: hosts})
+}
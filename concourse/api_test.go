@@ -0,0 +1,158 @@
+package summary_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+func apiGet(config *summary.Config, path string) (*httptest.ResponseRecorder, map[string]interface{}) {
+	mockRecorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+	Router(config).ServeHTTP(mockRecorder, req)
+
+	var body map[string]interface{}
+	Ω(json.Unmarshal(mockRecorder.Body.Bytes(), &body)).Should(Succeed())
+	return mockRecorder, body
+}
+
+var _ = Describe("JSON API", func() {
+	var config *summary.Config
+
+	BeforeEach(func() {
+		setupMultiple([]MockRoute{
+			{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/jobs", buildsJobsPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/resources", brokenResourcesPayload, 200, "", nil},
+		})
+		config = &summary.Config{
+			Protocol: "http",
+			Hosts:    []summary.Host{{FQDN: Host(server)}},
+			CSGroups: []summary.CSGroup{
+				{
+					Group: "test",
+					Hosts: []summary.Host{
+						{
+							FQDN:      Host(server),
+							Pipelines: []summary.Pipeline{{Name: "test1", Groups: []string{"build"}}},
+						},
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		teardown()
+	})
+
+	Describe("/api/v1/host/{host}", func() {
+		It("serves the pipelines of the host with their percentages", func() {
+			mockRecorder, body := apiGet(config, fmt.Sprintf("/api/v1/host/%s", Host(server)))
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(mockRecorder.Header().Get("Content-Type")).Should(Equal("application/json"))
+			Ω(body["version"]).Should(Equal("v1"))
+			Ω(body["host"]).Should(Equal(Host(server)))
+			Ω(body["fetched_at"]).ShouldNot(BeNil())
+			Ω(body["attempted_at"]).ShouldNot(BeNil())
+			Ω(body).ShouldNot(HaveKey("error"))
+
+			pipelines := body["pipelines"].([]interface{})
+			Ω(pipelines).Should(HaveLen(2))
+
+			build := pipelines[0].(map[string]interface{})
+			Ω(build["team"]).Should(Equal(""))
+			Ω(build["pipeline"]).Should(Equal("test1"))
+			Ω(build["group"]).Should(Equal("build"))
+			Ω(build["pipeline_url"]).Should(Equal(fmt.Sprintf("http://%s/test1.url?groups=build", Host(server))))
+			Ω(build["running"]).Should(Equal(false))
+			Ω(build["paused"]).Should(Equal(false))
+			Ω(build["broken_resource"]).Should(Equal(true))
+			Ω(build["statuses"]).Should(Equal(map[string]interface{}{"succeeded": float64(1)}))
+			Ω(build["percentages"]).Should(Equal(map[string]interface{}{"succeeded": float64(100)}))
+			Ω(build).ShouldNot(HaveKey("Jobs"))
+
+			deploy := pipelines[1].(map[string]interface{})
+			Ω(deploy["group"]).Should(Equal("deploy"))
+			Ω(deploy["running"]).Should(Equal(true))
+			Ω(deploy["statuses"]).Should(Equal(map[string]interface{}{"paused_job": float64(1)}))
+		})
+
+		Context("when concourse returns an error", func() {
+			BeforeEach(func() {
+				teardown()
+				setupMultiple([]MockRoute{
+					{"GET", "/api/v1/teams/pipelines", "", 403, "", nil},
+				})
+				config.Hosts = []summary.Host{{FQDN: Host(server)}}
+			})
+
+			It("returns the error class", func() {
+				mockRecorder, body := apiGet(config, fmt.Sprintf("/api/v1/host/%s", Host(server)))
+				Ω(mockRecorder.Code).Should(Equal(500))
+				Ω(body["error"]).Should(Equal("forbidden"))
+				Ω(body["fetched_at"]).Should(BeNil())
+				Ω(body["pipelines"]).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("/api/v1/hosts", func() {
+		It("serves every configured host", func() {
+			mockRecorder, body := apiGet(config, "/api/v1/hosts")
+			Ω(mockRecorder.Code).Should(Equal(200))
+
+			hosts := body["hosts"].([]interface{})
+			Ω(hosts).Should(HaveLen(1))
+			Ω(hosts[0].(map[string]interface{})["host"]).Should(Equal(Host(server)))
+			Ω(hosts[0].(map[string]interface{})["pipelines"]).Should(HaveLen(2))
+		})
+	})
+
+	Describe("/api/v1/group/{group}", func() {
+		It("serves the pipelines selected by the group", func() {
+			mockRecorder, body := apiGet(config, "/api/v1/group/test")
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(body["group"]).Should(Equal("test"))
+
+			hosts := body["hosts"].([]interface{})
+			Ω(hosts).Should(HaveLen(1))
+			pipelines := hosts[0].(map[string]interface{})["pipelines"].([]interface{})
+			Ω(pipelines).Should(HaveLen(1))
+			Ω(pipelines[0].(map[string]interface{})["group"]).Should(Equal("build"))
+		})
+
+		It("returns not found for an unknown group", func() {
+			mockRecorder, body := apiGet(config, "/api/v1/group/missing")
+			Ω(mockRecorder.Code).Should(Equal(404))
+			Ω(body["error"]).Should(Equal("group (missing) not found"))
+		})
+	})
+
+	Describe("/api/v1/host/{host}/pipeline/{pipeline}", func() {
+		It("serves the jobs of the pipeline", func() {
+			mockRecorder, body := apiGet(config, fmt.Sprintf("/api/v1/host/%s/pipeline/test1", Host(server)))
+			Ω(mockRecorder.Code).Should(Equal(200))
+
+			jobs := body["jobs"].([]interface{})
+			Ω(jobs).Should(HaveLen(2))
+			unit := jobs[0].(map[string]interface{})
+			Ω(unit["name"]).Should(Equal("unit"))
+			Ω(unit["status"]).Should(Equal("succeeded"))
+			Ω(unit["latest_build"]).Should(Equal("12"))
+			Ω(unit["build_url"]).Should(Equal(fmt.Sprintf("http://%s/teams/main/pipelines/test1/jobs/unit/builds/12", Host(server))))
+			Ω(unit["start_time"]).Should(Equal("2017-09-07T16:00:00Z"))
+		})
+
+		It("returns not found for an unknown pipeline", func() {
+			mockRecorder, _ := apiGet(config, fmt.Sprintf("/api/v1/host/%s/pipeline/missing", Host(server)))
+			Ω(mockRecorder.Code).Should(Equal(404))
+		})
+	})
+})
@@ -0,0 +1,186 @@
+package summary
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/concourse/go-concourse/concourse"
+)
+
+// tokens are refreshed this long before they expire so in-flight requests don't
+// race the expiry
+var tokenExpiryMargin = time.Minute
+
+// Credentials are used to authenticate with a concourse host, either with a static
+// bearer token or with a basic auth username and password exchanged for a token
+type Credentials struct {
+	Token        string `json:"token"`
+	TokenFile    string `json:"token_file"`
+	Username     string `json:"username"`
+	Password     string `json:"password"`
+	PasswordFile string `json:"password_file"`
+}
+
+func (c Credentials) empty() bool {
+	return c.Token == "" && c.Username == ""
+}
+
+// resolve reads any credentials held in files
+func (c Credentials) resolve() (Credentials, error) {
+	if c.TokenFile != "" {
+		token, err := ioutil.ReadFile(c.TokenFile)
+		if err != nil {
+			return Credentials{}, err
+		}
+		c.Token = strings.TrimSpace(string(token))
+	}
+	if c.PasswordFile != "" {
+		password, err := ioutil.ReadFile(c.PasswordFile)
+		if err != nil {
+			return Credentials{}, err
+		}
+		c.Password = strings.TrimSpace(string(password))
+	}
+	if c.Token != "" && c.Username != "" {
+		return Credentials{}, errors.New("credentials must have either a token or a username, not both")
+	}
+	return c, nil
+}
+
+func parseCredentials(credentialsJSON string) (map[string]Credentials, error) {
+	credentials := map[string]Credentials{}
+
+	if credentialsJSON == "" {
+		return credentials, nil
+	}
+
+	if err := json.Unmarshal([]byte(credentialsJSON), &credentials); err != nil {
+		return nil, err
+	}
+
+	for host, hostCredentials := range credentials {
+		resolved, err := hostCredentials.resolve()
+		if err != nil {
+			return nil, fmt.Errorf("credentials for %s: %s", host, err.Error())
+		}
+		credentials[host] = resolved
+	}
+	return credentials, nil
+}
+
+// authTransport adds an Authorization header to every request sent to a concourse
+// host, exchanging basic auth credentials for a team token when required
+type authTransport struct {
+	base        http.RoundTripper
+	credentials Credentials
+	uri         string
+	team        string
+
+	mutex  sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.authorization(false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(authorize(req, token))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || t.credentials.Username == "" || req.Method != http.MethodGet {
+		return resp, err
+	}
+
+	// the token may have been revoked or expired early, so exchange it once more
+	resp.Body.Close()
+	token, err = t.authorization(true)
+	if err != nil {
+		return nil, err
+	}
+	return t.base.RoundTrip(authorize(req, token))
+}
+
+func (t *authTransport) authorization(renew bool) (string, error) {
+	if t.credentials.Token != "" {
+		return "Bearer " + t.credentials.Token, nil
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if !renew && t.token != "" && (t.expiry.IsZero() || time.Now().Add(tokenExpiryMargin).Before(t.expiry)) {
+		return t.token, nil
+	}
+
+	client := concourse.NewClient(t.uri, &http.Client{
+		Transport: &basicAuthTransport{base: t.base, username: t.credentials.Username, password: t.credentials.Password},
+		Timeout:   time.Duration(30) * time.Second,
+	}, false)
+
+	authToken, err := client.Team(t.team).AuthToken()
+	if err != nil {
+		return "", err
+	}
+
+	t.token = fmt.Sprintf("%s %s", authToken.Type, authToken.Value)
+	t.expiry = tokenExpiry(authToken.Value)
+	return t.token, nil
+}
+
+type basicAuthTransport struct {
+	base     http.RoundTripper
+	username string
+	password string
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	clone := cloneRequest(req)
+	clone.SetBasicAuth(t.username, t.password)
+	return t.base.RoundTrip(clone)
+}
+
+func authorize(req *http.Request, authorization string) *http.Request {
+	clone := cloneRequest(req)
+	clone.Header.Set("Authorization", authorization)
+	return clone
+}
+
+func cloneRequest(req *http.Request) *http.Request {
+	clone := new(http.Request)
+	*clone = *req
+	clone.Header = make(http.Header, len(req.Header))
+	for key, values := range req.Header {
+		clone.Header[key] = append([]string(nil), values...)
+	}
+	return clone
+}
+
+// tokenExpiry reads the expiry from a JWT, tokens which can't be parsed are
+// treated as never expiring and are only renewed when concourse rejects them
+func tokenExpiry(token string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}
+	}
+
+	var claims struct {
+		Expiry int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Expiry == 0 {
+		return time.Time{}
+	}
+	return time.Unix(claims.Expiry, 0)
+}
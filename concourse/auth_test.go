@@ -0,0 +1,164 @@
+package summary_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/concourse/go-concourse/concourse"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+func fakeJWT(expiry time.Time) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return fmt.Sprintf("%s.%s.%s",
+		encode([]byte(`{"alg":"RS256","typ":"JWT"}`)),
+		encode([]byte(fmt.Sprintf(`{"exp":%d,"teamName":"main"}`, expiry.Unix()))),
+		encode([]byte("signature")),
+	)
+}
+
+type authServer struct {
+	mutex       sync.Mutex
+	token       string
+	exchanges   int
+	authHeaders []string
+}
+
+func (a *authServer) handler(w http.ResponseWriter, r *http.Request) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	switch r.URL.Path {
+	case "/api/v1/teams/main/auth/token":
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "admin" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		a.exchanges++
+		fmt.Fprintf(w, `{"type": "Bearer", "value": "%s"}`, a.token)
+	case "/api/v1/teams/main/pipelines":
+		a.authHeaders = append(a.authHeaders, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer "+a.token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, "[]")
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (a *authServer) setToken(token string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.token = token
+}
+
+func (a *authServer) Exchanges() int {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.exchanges
+}
+
+var _ = Describe("authenticated concourse access", func() {
+	var (
+		fakeATC         *authServer
+		atcServer       *httptest.Server
+		config          *summary.Config
+		credentialsJSON string
+	)
+
+	BeforeEach(func() {
+		fakeATC = &authServer{token: fakeJWT(time.Now().Add(time.Hour))}
+		atcServer = httptest.NewServer(http.HandlerFunc(fakeATC.handler))
+		credentialsJSON = ""
+	})
+
+	AfterEach(func() {
+		atcServer.Close()
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		config, err = summary.SetupConfig("", "", "", "", "", "", credentialsJSON)
+		Ω(err).Should(BeNil())
+		config.Protocol = "http"
+	})
+
+	Context("when a host has no credentials", func() {
+		It("does not send an Authorization header", func() {
+			snapshot := config.Cache.Refresh(Host(atcServer))
+			Ω(snapshot.Err).Should(Equal(concourse.ErrUnauthorized))
+			Ω(fakeATC.authHeaders).Should(Equal([]string{""}))
+		})
+	})
+
+	Context("when a host has a static token", func() {
+		BeforeEach(func() {
+			credentialsJSON = fmt.Sprintf(`{"%s": {"token": "%s"}}`, Host(atcServer), fakeATC.token)
+		})
+
+		It("sends the token as a bearer token", func() {
+			snapshot := config.Cache.Refresh(Host(atcServer))
+			Ω(snapshot.Err).Should(BeNil())
+			Ω(fakeATC.authHeaders).Should(Equal([]string{"Bearer " + fakeATC.token}))
+			Ω(fakeATC.Exchanges()).Should(Equal(0))
+		})
+	})
+
+	Context("when a host has basic auth credentials", func() {
+		BeforeEach(func() {
+			credentialsJSON = fmt.Sprintf(`{"%s": {"username": "admin", "password": "secret"}}`, Host(atcServer))
+		})
+
+		It("exchanges the credentials for a token and reuses it", func() {
+			Ω(config.Cache.Refresh(Host(atcServer)).Err).Should(BeNil())
+			Ω(config.Cache.Refresh(Host(atcServer)).Err).Should(BeNil())
+			Ω(fakeATC.Exchanges()).Should(Equal(1))
+		})
+
+		Context("and the token has expired", func() {
+			BeforeEach(func() {
+				fakeATC.setToken(fakeJWT(time.Now().Add(-time.Hour)))
+			})
+
+			It("exchanges the credentials for a new token on every fetch", func() {
+				Ω(config.Cache.Refresh(Host(atcServer)).Err).Should(BeNil())
+				Ω(config.Cache.Refresh(Host(atcServer)).Err).Should(BeNil())
+				Ω(fakeATC.Exchanges()).Should(Equal(2))
+			})
+		})
+
+		Context("and concourse stops accepting the token", func() {
+			It("exchanges the credentials again and retries", func() {
+				Ω(config.Cache.Refresh(Host(atcServer)).Err).Should(BeNil())
+				fakeATC.setToken(fakeJWT(time.Now().Add(2 * time.Hour)))
+
+				Ω(config.Cache.Refresh(Host(atcServer)).Err).Should(BeNil())
+				Ω(fakeATC.Exchanges()).Should(Equal(2))
+			})
+		})
+
+		Context("and the credentials are wrong", func() {
+			BeforeEach(func() {
+				credentialsJSON = fmt.Sprintf(`{"%s": {"username": "admin", "password": "wrong"}}`, Host(atcServer))
+			})
+
+			It("returns an unauthorized error", func() {
+				snapshot := config.Cache.Refresh(Host(atcServer))
+				Ω(snapshot.Err).Should(MatchError(ContainSubstring(concourse.ErrUnauthorized.Error())))
+			})
+		})
+	})
+})
@@ -0,0 +1,160 @@
+package summary
+
+import (
+	"fmt"
+	"net/http"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+)
+
+// badgeStatuses are the statuses a badge can show, most severe first
+var badgeStatuses = []string{"failing", "errored", "unknown", "paused", "running", "passing", "pending"}
+
+var badgeColors = map[string]string{
+	"failing":   "#E74C3C",
+	"errored":   "#E67E21",
+	"unknown":   "#5C6C7D",
+	"paused":    "#3498DB",
+	"running":   "#F2C500",
+	"passing":   "#2ECC71",
+	"pending":   "#5C6C7D",
+	"not found": "#5C6C7D",
+}
+
+type badge struct {
+	Label  string
+	Status string
+}
+
+// textWidth estimates the rendered width of text in the badge font
+func textWidth(text string) int {
+	return utf8.RuneCountInString(text)*7 + 10
+}
+
+func (b badge) LabelWidth() int {
+	return textWidth(b.Label)
+}
+
+func (b badge) StatusWidth() int {
+	return textWidth(b.Status)
+}
+
+func (b badge) Width() int {
+	return b.LabelWidth() + b.StatusWidth()
+}
+
+func (b badge) LabelX() int {
+	return b.LabelWidth() / 2
+}
+
+func (b badge) StatusX() int {
+	return b.LabelWidth() + b.StatusWidth()/2
+}
+
+func (b badge) Color() string {
+	return badgeColors[b.Status]
+}
+
+// badgeStatus aggregates the state of pipeline groups into the status shown on a
+// badge, the most severe status of any pipeline group wins and running is only
+// shown over passing and pending
+func badgeStatus(data []Data, hostError bool) string {
+	if len(data) == 0 && !hostError {
+		return "unknown"
+	}
+
+	statuses := map[string]bool{"unknown": hostError}
+	for _, datum := range data {
+		switch datum.State() {
+		case "failed":
+			statuses["failing"] = true
+		case "errored":
+			statuses["errored"] = true
+		case "paused":
+			statuses["paused"] = true
+		case "succeeded":
+			statuses["passing"] = true
+		}
+		if datum.Running && !datum.Paused {
+			statuses["running"] = true
+		}
+	}
+
+	for _, status := range badgeStatuses {
+		if statuses[status] {
+			return status
+		}
+	}
+	return "pending"
+}
+
+func (config *Config) writeBadge(w http.ResponseWriter, code int, b badge) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-cache, max-age=0")
+	w.WriteHeader(code)
+
+	err := config.Templates.ExecuteTemplate(w, "badge", b)
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// PipelineBadge serves a badge with the status of a pipeline, limited to a single
+// pipeline group and team when they are given
+func (config *Config) PipelineBadge(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	host := vars["host"]
+	pipeline := Pipeline{Team: r.URL.Query().Get("team"), Name: vars["pipeline"]}
+	if group := r.URL.Query().Get("group"); group != "" {
+		pipeline.Groups = []string{group}
+	}
+
+	label := r.URL.Query().Get("label")
+	if label == "" {
+		label = pipeline.Name
+	}
+
+	snapshot := config.hostSnapshot(host)
+	if snapshot.Err != nil {
+		fmt.Println(snapshot.Err.Error())
+		config.writeBadge(w, http.StatusInternalServerError, badge{Label: label, Status: "unknown"})
+		return
+	}
+
+	data := filterData(snapshot.Data, []Pipeline{pipeline})
+	if len(data) == 0 {
+		config.writeBadge(w, http.StatusNotFound, badge{Label: label, Status: "not found"})
+		return
+	}
+	config.writeBadge(w, http.StatusOK, badge{Label: label, Status: badgeStatus(data, false)})
+}
+
+// GroupBadge serves a badge with the status of every pipeline in a concourse summary
+// group, hosts which fail show as unknown unless a pipeline is failing or errored
+func (config *Config) GroupBadge(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+
+	label := r.URL.Query().Get("label")
+	if label == "" {
+		label = group
+	}
+
+	csGroup := config.CSGroups.group(group)
+	if csGroup.Group == "" {
+		config.writeBadge(w, http.StatusNotFound, badge{Label: label, Status: "not found"})
+		return
+	}
+
+	var (
+		data      []Data
+		hostError bool
+	)
+	for _, groupData := range config.groupData(csGroup) {
+		if groupData.Error != "" {
+			hostError = true
+		}
+		data = append(data, groupData.Statuses...)
+	}
+	config.writeBadge(w, http.StatusOK, badge{Label: label, Status: badgeStatus(data, hostError)})
+}
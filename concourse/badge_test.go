@@ -0,0 +1,164 @@
+package summary_test
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+var _ = Describe("Badges", func() {
+	var (
+		templates    = template.Must(template.ParseGlob("../templates/*"))
+		config       *summary.Config
+		mockRecorder *httptest.ResponseRecorder
+		path         string
+	)
+
+	BeforeEach(func() {
+		setupMultiple([]MockRoute{
+			{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/jobs", buildsJobsPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
+		})
+		config = &summary.Config{
+			Templates: templates,
+			Protocol:  "http",
+			CSGroups: []summary.CSGroup{
+				{
+					Group: "build",
+					Hosts: []summary.Host{
+						{
+							FQDN:      Host(server),
+							Pipelines: []summary.Pipeline{{Name: "test1", Groups: []string{"build"}}},
+						},
+					},
+				},
+				{
+					Group: "broken",
+					Hosts: []summary.Host{
+						{
+							FQDN:      Host(server),
+							Pipelines: []summary.Pipeline{{Name: "test1", Groups: []string{"build"}}},
+						},
+						{FQDN: "127.0.0.1:1"},
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		teardown()
+	})
+
+	JustBeforeEach(func() {
+		mockRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+		Router(config).ServeHTTP(mockRecorder, req)
+	})
+
+	Describe("/badge/{host}/{pipeline}.svg", func() {
+		Context("for a whole pipeline", func() {
+			BeforeEach(func() {
+				path = fmt.Sprintf("/badge/%s/test1.svg", Host(server))
+			})
+
+			It("serves an svg badge with the pipeline status", func() {
+				Ω(mockRecorder.Code).Should(Equal(200))
+				Ω(mockRecorder.Header().Get("Content-Type")).Should(Equal("image/svg+xml"))
+				Ω(mockRecorder.Header().Get("Cache-Control")).Should(Equal("no-cache, max-age=0"))
+				Ω(mockRecorder.Body.String()).Should(HavePrefix(`<svg xmlns="http://www.w3.org/2000/svg" width="104" height="20">`))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`<text x="22" y="14">test1</text>`))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`<text x="74" y="14">running</text>`))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`fill="#F2C500"`))
+			})
+		})
+
+		Context("for a single group", func() {
+			BeforeEach(func() {
+				path = fmt.Sprintf("/badge/%s/test1.svg?group=build&label=unit%%20tests", Host(server))
+			})
+
+			It("only considers the jobs of that group", func() {
+				Ω(mockRecorder.Code).Should(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`>unit tests</text>`))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`>passing</text>`))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`fill="#2ECC71"`))
+			})
+		})
+
+		Context("for a pipeline which does not exist", func() {
+			BeforeEach(func() {
+				path = fmt.Sprintf("/badge/%s/missing.svg", Host(server))
+			})
+
+			It("serves a not found badge", func() {
+				Ω(mockRecorder.Code).Should(Equal(404))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`>not found</text>`))
+			})
+		})
+
+		Context("when the host can't be fetched", func() {
+			BeforeEach(func() {
+				path = "/badge/127.0.0.1:1/test1.svg"
+			})
+
+			It("serves an unknown badge", func() {
+				Ω(mockRecorder.Code).Should(Equal(500))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`>unknown</text>`))
+			})
+		})
+	})
+
+	Describe("/badge/group/{group}.svg", func() {
+		Context("for a group", func() {
+			BeforeEach(func() {
+				path = "/badge/group/build.svg"
+			})
+
+			It("serves a badge with the status of the pipelines selected by the group", func() {
+				Ω(mockRecorder.Code).Should(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`>build</text>`))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`>passing</text>`))
+			})
+		})
+
+		Context("for a group with a host which can't be fetched", func() {
+			BeforeEach(func() {
+				path = "/badge/group/broken.svg"
+			})
+
+			It("serves an unknown badge", func() {
+				Ω(mockRecorder.Code).Should(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`>unknown</text>`))
+			})
+		})
+
+		Context("for a group which does not exist", func() {
+			BeforeEach(func() {
+				path = "/badge/group/missing.svg"
+			})
+
+			It("serves a not found badge", func() {
+				Ω(mockRecorder.Code).Should(Equal(404))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring(`>not found</text>`))
+			})
+		})
+	})
+})
+
+var _ = Describe("Data#State", func() {
+	It("is paused when the pipeline is paused", func() {
+		Ω(summary.Data{Paused: true, Statuses: map[string]int{"failed": 1}}.State()).Should(Equal("paused"))
+	})
+
+	It("is the build status otherwise", func() {
+		Ω(summary.Data{Statuses: map[string]int{"failed": 1}}.State()).Should(Equal("failed"))
+	})
+})
@@ -0,0 +1,238 @@
+package summary
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Snapshot is the most recent data collected from a concourse host, along with
+// counts of the fetches made so far
+type Snapshot struct {
+	Host          string
+	Data          []Data
+	FetchedAt     time.Time
+	AttemptedAt   time.Time
+	FetchDuration time.Duration
+	Fetches       int
+	Errors        int
+	Err           error
+}
+
+// Cache holds the latest snapshot for each concourse host so that pages can be
+// served without querying concourse on every request
+type Cache struct {
+	MaxAge time.Duration
+
+	fetch       func(host string) ([]Data, error)
+	mutex       sync.RWMutex
+	entries     map[string]*cacheEntry
+	subscribers map[chan Snapshot]bool
+	watchers    map[*cacheWatcher]bool
+}
+
+type cacheWatcher struct {
+	watch func(Snapshot)
+}
+
+type cacheEntry struct {
+	fetchMutex sync.Mutex
+	snapshot   Snapshot
+}
+
+// NewCache creates a cache which collects data using fetch, snapshots older than
+// maxAge are refreshed on access
+func NewCache(maxAge time.Duration, fetch func(host string) ([]Data, error)) *Cache {
+	return &Cache{
+		MaxAge:      maxAge,
+		fetch:       fetch,
+		entries:     map[string]*cacheEntry{},
+		subscribers: map[chan Snapshot]bool{},
+		watchers:    map[*cacheWatcher]bool{},
+	}
+}
+
+// Get returns the snapshot for a host, fetching it first if it is missing or stale
+func (c *Cache) Get(host string) Snapshot {
+	entry := c.entry(host)
+
+	snapshot, fresh := c.fresh(entry)
+	if fresh {
+		return snapshot
+	}
+
+	entry.fetchMutex.Lock()
+	defer entry.fetchMutex.Unlock()
+
+	// another request may have refreshed the host while we were waiting
+	if snapshot, fresh := c.fresh(entry); fresh {
+		return snapshot
+	}
+	return c.refresh(host, entry)
+}
+
+// Refresh fetches the data for a host and stores it regardless of its age
+func (c *Cache) Refresh(host string) Snapshot {
+	entry := c.entry(host)
+
+	entry.fetchMutex.Lock()
+	defer entry.fetchMutex.Unlock()
+
+	return c.refresh(host, entry)
+}
+
+// Snapshot returns the stored snapshot for a host without fetching
+func (c *Cache) Snapshot(host string) (Snapshot, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	entry, ok := c.entries[host]
+	if !ok || entry.snapshot.AttemptedAt.IsZero() {
+		return Snapshot{}, false
+	}
+	return entry.snapshot, true
+}
+
+// Snapshots returns the stored snapshot of every host which has been fetched at
+// least once, ordered by host
+func (c *Cache) Snapshots() []Snapshot {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	var snapshots []Snapshot
+	for _, entry := range c.entries {
+		if !entry.snapshot.AttemptedAt.IsZero() {
+			snapshots = append(snapshots, entry.snapshot)
+		}
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Host < snapshots[j].Host
+	})
+	return snapshots
+}
+
+// Subscribe returns a channel which receives every snapshot stored after the call,
+// and a function to stop the subscription. Snapshots are dropped rather than
+// blocking a fetch when the subscriber falls behind
+func (c *Cache) Subscribe() (<-chan Snapshot, func()) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	updates := make(chan Snapshot, 16)
+	c.subscribers[updates] = true
+	return updates, func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		delete(c.subscribers, updates)
+	}
+}
+
+// Watch calls watch with every snapshot stored after the call, until the returned
+// function is called. Unlike Subscribe no snapshot is missed: watch is called by the
+// goroutine which fetched the snapshot, in order for each host, so it must not block
+func (c *Cache) Watch(watch func(Snapshot)) func() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	watcher := &cacheWatcher{watch: watch}
+	c.watchers[watcher] = true
+	return func() {
+		c.mutex.Lock()
+		defer c.mutex.Unlock()
+
+		delete(c.watchers, watcher)
+	}
+}
+
+// reconfigure changes how hosts are fetched and how long their snapshots are kept,
+// the snapshots already stored and subscribers carry on
+func (c *Cache) reconfigure(maxAge time.Duration, fetch func(host string) ([]Data, error)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.MaxAge = maxAge
+	c.fetch = fetch
+}
+
+// retain removes the snapshots of every host not in hosts, so hosts which are no
+// longer polled aren't served or reported
+func (c *Cache) retain(hosts []string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	kept := map[string]bool{}
+	for _, host := range hosts {
+		kept[host] = true
+	}
+	for host := range c.entries {
+		if !kept[host] {
+			delete(c.entries, host)
+		}
+	}
+}
+
+func (c *Cache) entry(host string) *cacheEntry {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[host]
+	if !ok {
+		entry = &cacheEntry{snapshot: Snapshot{Host: host}}
+		c.entries[host] = entry
+	}
+	return entry
+}
+
+func (c *Cache) fresh(entry *cacheEntry) (Snapshot, bool) {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	snapshot := entry.snapshot
+	if snapshot.AttemptedAt.IsZero() {
+		return snapshot, false
+	}
+	return snapshot, time.Since(snapshot.AttemptedAt) < c.MaxAge
+}
+
+func (c *Cache) refresh(host string, entry *cacheEntry) Snapshot {
+	c.mutex.RLock()
+	fetch := c.fetch
+	c.mutex.RUnlock()
+
+	started := time.Now()
+	data, err := fetch(host)
+	now := time.Now()
+
+	c.mutex.Lock()
+
+	entry.snapshot.AttemptedAt = now
+	entry.snapshot.FetchDuration = now.Sub(started)
+	entry.snapshot.Fetches++
+	entry.snapshot.Err = err
+	if err != nil {
+		entry.snapshot.Errors++
+	} else {
+		entry.snapshot.Data = data
+		entry.snapshot.FetchedAt = now
+	}
+
+	snapshot := entry.snapshot
+	for subscriber := range c.subscribers {
+		select {
+		case subscriber <- snapshot:
+		default:
+		}
+	}
+	var watchers []*cacheWatcher
+	for watcher := range c.watchers {
+		watchers = append(watchers, watcher)
+	}
+	c.mutex.Unlock()
+
+	// the host's fetch mutex is still held, so watchers see its snapshots in order
+	for _, watcher := range watchers {
+		watcher.watch(snapshot)
+	}
+	return snapshot
+}
@@ -0,0 +1,255 @@
+package summary_test
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+type fakeFetcher struct {
+	mutex sync.Mutex
+	calls map[string]int
+	data  []summary.Data
+	err   error
+}
+
+func (f *fakeFetcher) fetch(host string) ([]summary.Data, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.calls == nil {
+		f.calls = map[string]int{}
+	}
+	f.calls[host]++
+	return f.data, f.err
+}
+
+func (f *fakeFetcher) Calls(host string) int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.calls[host]
+}
+
+var _ = Describe("Cache", func() {
+	var (
+		fetcher *fakeFetcher
+		cache   *summary.Cache
+		maxAge  time.Duration
+	)
+
+	BeforeEach(func() {
+		maxAge = time.Minute
+		fetcher = &fakeFetcher{
+			data: []summary.Data{{Pipeline: "test1"}},
+		}
+	})
+
+	JustBeforeEach(func() {
+		cache = summary.NewCache(maxAge, fetcher.fetch)
+	})
+
+	Describe("#Get", func() {
+		It("fetches a host the first time it is requested", func() {
+			snapshot := cache.Get("host1")
+			Ω(snapshot.Err).Should(BeNil())
+			Ω(snapshot.Host).Should(Equal("host1"))
+			Ω(snapshot.Data).Should(Equal([]summary.Data{{Pipeline: "test1"}}))
+			Ω(snapshot.FetchedAt).ShouldNot(BeZero())
+			Ω(fetcher.Calls("host1")).Should(Equal(1))
+		})
+
+		It("serves repeat requests from the cache", func() {
+			for i := 0; i < 10; i++ {
+				cache.Get("host1")
+			}
+			Ω(fetcher.Calls("host1")).Should(Equal(1))
+		})
+
+		It("only fetches once for concurrent requests", func() {
+			var waitGroup sync.WaitGroup
+			for i := 0; i < 10; i++ {
+				waitGroup.Add(1)
+				go func() {
+					defer waitGroup.Done()
+					cache.Get("host1")
+				}()
+			}
+			waitGroup.Wait()
+			Ω(fetcher.Calls("host1")).Should(Equal(1))
+		})
+
+		Context("when the snapshot is stale", func() {
+			BeforeEach(func() {
+				maxAge = time.Nanosecond
+			})
+
+			It("fetches the host again", func() {
+				cache.Get("host1")
+				time.Sleep(time.Millisecond)
+				cache.Get("host1")
+				Ω(fetcher.Calls("host1")).Should(Equal(2))
+			})
+		})
+
+		Context("when fetching fails", func() {
+			BeforeEach(func() {
+				fetcher.err = errors.New("boom")
+			})
+
+			It("returns the error without a fetch time", func() {
+				snapshot := cache.Get("host1")
+				Ω(snapshot.Err).Should(MatchError("boom"))
+				Ω(snapshot.Data).Should(BeNil())
+				Ω(snapshot.FetchedAt).Should(BeZero())
+				Ω(snapshot.AttemptedAt).ShouldNot(BeZero())
+			})
+		})
+	})
+
+	Describe("#Refresh", func() {
+		It("fetches the host even when the snapshot is fresh", func() {
+			cache.Get("host1")
+			cache.Refresh("host1")
+			Ω(fetcher.Calls("host1")).Should(Equal(2))
+		})
+
+		Context("when a refresh fails after a successful fetch", func() {
+			It("keeps the last successful data", func() {
+				first := cache.Refresh("host1")
+				fetcher.mutex.Lock()
+				fetcher.err = errors.New("boom")
+				fetcher.mutex.Unlock()
+
+				snapshot := cache.Refresh("host1")
+				Ω(snapshot.Err).Should(MatchError("boom"))
+				Ω(snapshot.Data).Should(Equal([]summary.Data{{Pipeline: "test1"}}))
+				Ω(snapshot.FetchedAt).Should(Equal(first.FetchedAt))
+			})
+		})
+	})
+
+	Describe("#Snapshot", func() {
+		It("does not fetch hosts", func() {
+			_, ok := cache.Snapshot("host1")
+			Ω(ok).Should(BeFalse())
+			Ω(fetcher.Calls("host1")).Should(Equal(0))
+		})
+
+		It("returns stored snapshots", func() {
+			cache.Get("host1")
+			snapshot, ok := cache.Snapshot("host1")
+			Ω(ok).Should(BeTrue())
+			Ω(snapshot.Data).Should(Equal([]summary.Data{{Pipeline: "test1"}}))
+		})
+	})
+
+	Describe("#Watch", func() {
+		It("calls watchers with every refreshed snapshot until they stop watching", func() {
+			var (
+				mutex     sync.Mutex
+				snapshots []summary.Snapshot
+			)
+			unwatch := cache.Watch(func(snapshot summary.Snapshot) {
+				mutex.Lock()
+				defer mutex.Unlock()
+
+				snapshots = append(snapshots, snapshot)
+			})
+			for i := 0; i < 40; i++ {
+				cache.Refresh("host1")
+			}
+			unwatch()
+			cache.Refresh("host1")
+
+			mutex.Lock()
+			defer mutex.Unlock()
+			Ω(snapshots).Should(HaveLen(40))
+			Ω(snapshots[39].Fetches).Should(Equal(40))
+		})
+	})
+
+	Describe("#Subscribe", func() {
+		It("sends subscribers every refreshed snapshot until they unsubscribe", func() {
+			updates, unsubscribe := cache.Subscribe()
+			cache.Refresh("host1")
+			cache.Get("host1")
+			cache.Refresh("host2")
+			var snapshot summary.Snapshot
+			Ω(updates).Should(Receive(&snapshot))
+			Ω(snapshot.Host).Should(Equal("host1"))
+			Ω(snapshot.Data).Should(Equal([]summary.Data{{Pipeline: "test1"}}))
+			Ω(updates).Should(Receive(&snapshot))
+			Ω(snapshot.Host).Should(Equal("host2"))
+			Ω(updates).ShouldNot(Receive())
+
+			unsubscribe()
+			cache.Refresh("host1")
+			Ω(updates).ShouldNot(Receive())
+		})
+	})
+})
+
+var _ = Describe("Poller", func() {
+	var (
+		fetcher *fakeFetcher
+		poller  *summary.Poller
+	)
+
+	BeforeEach(func() {
+		fetcher = &fakeFetcher{}
+		poller = summary.NewPoller(&summary.Config{
+			RefreshInterval: 1,
+			Hosts:           []summary.Host{{FQDN: "host1"}, {FQDN: "host2"}},
+			CSGroups: summary.CSGroups{
+				{Group: "test", Hosts: []summary.Host{{FQDN: "host2"}, {FQDN: "host3"}}},
+			},
+			Cache: summary.NewCache(time.Minute, fetcher.fetch),
+		})
+	})
+
+	AfterEach(func() {
+		poller.Stop()
+	})
+
+	It("polls every configured host once", func() {
+		Ω(poller.Hosts).Should(Equal([]string{"host1", "host2", "host3"}))
+		poller.Start()
+		for _, host := range poller.Hosts {
+			Eventually(func() int { return fetcher.Calls(host) }).Should(Equal(1))
+		}
+	})
+
+	It("keeps polling on the refresh interval", func() {
+		poller.Interval = 10 * time.Millisecond
+		poller.Start()
+		Eventually(func() int { return fetcher.Calls("host1") }).Should(BeNumerically(">=", 3))
+	})
+
+	It("only starts and stops the hosts which changed when reloaded", func() {
+		poller.Start()
+		for _, host := range poller.Hosts {
+			Eventually(func() int { return fetcher.Calls(host) }).Should(Equal(1))
+		}
+
+		poller.Reload(&summary.Config{
+			RefreshInterval: 1,
+			Hosts:           []summary.Host{{FQDN: "host1"}, {FQDN: "host4"}},
+		})
+		Ω(poller.Hosts).Should(Equal([]string{"host1", "host4"}))
+		Eventually(func() int { return fetcher.Calls("host4") }).Should(Equal(1))
+		Consistently(func() int { return fetcher.Calls("host1") }, 500*time.Millisecond).Should(Equal(1))
+
+		Eventually(func() int { return fetcher.Calls("host1") }, 2*time.Second).Should(Equal(2))
+		Ω(fetcher.Calls("host2")).Should(Equal(1))
+		Ω(fetcher.Calls("host3")).Should(Equal(1))
+
+		_, ok := poller.Cache.Snapshot("host2")
+		Ω(ok).Should(BeFalse())
+	})
+})
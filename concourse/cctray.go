@@ -0,0 +1,181 @@
+package summary
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+type ccProjects struct {
+	XMLName  xml.Name    `xml:"Projects"`
+	Projects []ccProject `xml:"Project"`
+}
+
+type ccProject struct {
+	Name            string `xml:"name,attr"`
+	Activity        string `xml:"activity,attr"`
+	LastBuildStatus string `xml:"lastBuildStatus,attr"`
+	LastBuildLabel  string `xml:"lastBuildLabel,attr,omitempty"`
+	LastBuildTime   string `xml:"lastBuildTime,attr"`
+	WebURL          string `xml:"webUrl,attr"`
+}
+
+// ccBuildStatus maps a concourse build status onto a CCTray lastBuildStatus
+func ccBuildStatus(status string) string {
+	switch status {
+	case "succeeded":
+		return "Success"
+	case "failed":
+		return "Failure"
+	case "errored", "aborted":
+		return "Exception"
+	}
+	return "Unknown"
+}
+
+func ccActivity(running bool) string {
+	if running {
+		return "Building"
+	}
+	return "Sleeping"
+}
+
+func ccTime(t time.Time) string {
+	return t.Format(time.RFC3339)
+}
+
+// ccName joins the parts of a project name, skipping those which are blank
+func ccName(parts ...string) string {
+	var name []string
+	for _, part := range parts {
+		if part != "" {
+			name = append(name, part)
+		}
+	}
+	return strings.Join(name, "/")
+}
+
+// lastBuildTime is when the most recent finished build of jobs ended, or fallback
+// when none of them have finished a build
+func lastBuildTime(jobs []JobData, fallback time.Time) time.Time {
+	var latest time.Time
+	for _, job := range jobs {
+		if job.FinishedAt.After(latest) {
+			latest = job.FinishedAt
+		}
+	}
+	if latest.IsZero() {
+		return fallback
+	}
+	return latest
+}
+
+// ccPipelineProjects returns a project for each pipeline group in data, the build
+// time of a pipeline group is that of its most recently finished job
+func ccPipelineProjects(prefix string, data []Data, fetchedAt time.Time) []ccProject {
+	var projects []ccProject
+	multiTeam := multiTeam(data)
+	for _, datum := range data {
+		team := ""
+		if multiTeam {
+			team = datum.Team
+		}
+
+		projects = append(projects, ccProject{
+			Name:            ccName(prefix, team, datum.Pipeline, datum.Group),
+			Activity:        ccActivity(datum.Running),
+			LastBuildStatus: ccBuildStatus(datum.BuildStatus()),
+			LastBuildTime:   ccTime(lastBuildTime(datum.Jobs, fetchedAt)),
+			WebURL:          datum.URL,
+		})
+	}
+	return projects
+}
+
+// ccJobProjects returns a project for each job in data
+func ccJobProjects(prefix string, data []Data, fetchedAt time.Time) []ccProject {
+	var projects []ccProject
+	multiTeam := multiTeam(data)
+	seen := map[string]bool{}
+	for _, datum := range data {
+		team := ""
+		if multiTeam {
+			team = datum.Team
+		}
+
+		for _, job := range datum.Jobs {
+			name := ccName(prefix, team, job.Pipeline, job.Name)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			projects = append(projects, ccProject{
+				Name:            name,
+				Activity:        ccActivity(job.Running),
+				LastBuildStatus: ccBuildStatus(job.Status),
+				LastBuildLabel:  job.FinishedBuildNum,
+				LastBuildTime:   ccTime(lastBuildTime([]JobData{job}, fetchedAt)),
+				WebURL:          job.URL,
+			})
+		}
+	}
+	return projects
+}
+
+func ccProjectsFor(r *http.Request, prefix string, data []Data, fetchedAt time.Time) []ccProject {
+	if r.URL.Query().Get("jobs") == "true" {
+		return ccJobProjects(prefix, data, fetchedAt)
+	}
+	return ccPipelineProjects(prefix, data, fetchedAt)
+}
+
+func writeCCTray(w http.ResponseWriter, projects []ccProject) {
+	w.Header().Set("Content-Type", "application/xml")
+	fmt.Fprint(w, xml.Header)
+	if err := xml.NewEncoder(w).Encode(ccProjects{Projects: projects}); err != nil {
+		fmt.Println(err.Error())
+	}
+}
+
+// HostCCTray serves the pipelines of a host as a CCTray feed
+func (config *Config) HostCCTray(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+	snapshot := config.hostSnapshot(host)
+	if snapshot.Err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error collecting data from concourse (%s) please refer to logs for more details", host)
+		fmt.Println(snapshot.Err.Error())
+		return
+	}
+
+	writeCCTray(w, ccProjectsFor(r, "", snapshot.Data, snapshot.FetchedAt))
+}
+
+// GroupCCTray serves the pipelines of a concourse summary group as a CCTray feed,
+// project names are prefixed with their host and hosts which fail are shown as a
+// single project with an unknown status
+func (config *Config) GroupCCTray(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+
+	var projects []ccProject
+	for _, groupData := range config.groupData(config.CSGroups.group(group)) {
+		if groupData.Error != "" {
+			projects = append(projects, ccProject{
+				Name:            groupData.Host,
+				Activity:        ccActivity(false),
+				LastBuildStatus: ccBuildStatus(""),
+				LastBuildTime:   ccTime(groupData.LastAttempted),
+				WebURL:          config.host(groupData.Host).URL(),
+			})
+			continue
+		}
+		projects = append(projects, ccProjectsFor(r, groupData.Host, groupData.Statuses, groupData.LastFetched)...)
+	}
+
+	writeCCTray(w, projects)
+}
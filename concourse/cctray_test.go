@@ -0,0 +1,156 @@
+package summary_test
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+type ccProject struct {
+	Name            string `xml:"name,attr"`
+	Activity        string `xml:"activity,attr"`
+	LastBuildStatus string `xml:"lastBuildStatus,attr"`
+	LastBuildLabel  string `xml:"lastBuildLabel,attr"`
+	LastBuildTime   string `xml:"lastBuildTime,attr"`
+	WebURL          string `xml:"webUrl,attr"`
+}
+
+func ccGet(config *summary.Config, path string) (*httptest.ResponseRecorder, []ccProject) {
+	mockRecorder := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "http://example.com"+path, nil)
+	Router(config).ServeHTTP(mockRecorder, req)
+
+	var projects struct {
+		Projects []ccProject `xml:"Project"`
+	}
+	if mockRecorder.Code == 200 {
+		Ω(xml.Unmarshal(mockRecorder.Body.Bytes(), &projects)).Should(Succeed())
+	}
+	return mockRecorder, projects.Projects
+}
+
+var _ = Describe("CCTray feeds", func() {
+	var config *summary.Config
+
+	BeforeEach(func() {
+		setupMultiple([]MockRoute{
+			{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/jobs", buildsJobsPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
+		})
+		config = &summary.Config{
+			Protocol: "http",
+			CSGroups: []summary.CSGroup{
+				{
+					Group: "test",
+					Hosts: []summary.Host{
+						{
+							FQDN:      Host(server),
+							Pipelines: []summary.Pipeline{{Name: "test1", Groups: []string{"build"}}},
+						},
+						{FQDN: "127.0.0.1:1"},
+					},
+				},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		teardown()
+	})
+
+	Describe("/host/{host}/cc.xml", func() {
+		It("serves a project for every pipeline group", func() {
+			mockRecorder, projects := ccGet(config, fmt.Sprintf("/host/%s/cc.xml", Host(server)))
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(mockRecorder.Header().Get("Content-Type")).Should(Equal("application/xml"))
+			Ω(mockRecorder.Body.String()).Should(HavePrefix(`<?xml version="1.0" encoding="UTF-8"?>`))
+
+			Ω(projects).Should(HaveLen(2))
+			Ω(projects[0]).Should(Equal(ccProject{
+				Name:            "test1/build",
+				Activity:        "Sleeping",
+				LastBuildStatus: "Success",
+				LastBuildTime:   time.Unix(1504800090, 0).Format(time.RFC3339),
+				WebURL:          fmt.Sprintf("http://%s/test1.url?groups=build", Host(server)),
+			}))
+			Ω(projects[1]).Should(Equal(ccProject{
+				Name:            "test1/deploy",
+				Activity:        "Building",
+				LastBuildStatus: "Unknown",
+				LastBuildTime:   time.Unix(1504803600, 0).Format(time.RFC3339),
+				WebURL:          fmt.Sprintf("http://%s/test1.url?groups=deploy", Host(server)),
+			}))
+		})
+
+		It("serves a project for every job when asked", func() {
+			mockRecorder, projects := ccGet(config, fmt.Sprintf("/host/%s/cc.xml?jobs=true", Host(server)))
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(projects).Should(HaveLen(2))
+			Ω(projects[1]).Should(Equal(ccProject{
+				Name:            "test1/deploy",
+				Activity:        "Building",
+				LastBuildStatus: "Failure",
+				LastBuildLabel:  "3",
+				LastBuildTime:   time.Unix(1504803600, 0).Format(time.RFC3339),
+				WebURL:          fmt.Sprintf("http://%s/teams/main/pipelines/test1/jobs/deploy", Host(server)),
+			}))
+		})
+
+		It("returns an error when the host can't be fetched", func() {
+			mockRecorder, _ := ccGet(config, "/host/127.0.0.1:1/cc.xml")
+			Ω(mockRecorder.Code).Should(Equal(500))
+		})
+	})
+
+	Describe("/group/{group}/cc.xml", func() {
+		It("serves the pipelines selected by the group prefixed by host", func() {
+			mockRecorder, projects := ccGet(config, "/group/test/cc.xml")
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(projects).Should(HaveLen(2))
+			Ω(projects[0].Name).Should(Equal(fmt.Sprintf("%s/test1/build", Host(server))))
+			Ω(projects[0].LastBuildStatus).Should(Equal("Success"))
+		})
+
+		It("serves hosts which fail as an unknown project", func() {
+			_, projects := ccGet(config, "/group/test/cc.xml")
+			Ω(projects[1]).Should(Equal(ccProject{
+				Name:            "127.0.0.1:1",
+				Activity:        "Sleeping",
+				LastBuildStatus: "Unknown",
+				LastBuildTime:   projects[1].LastBuildTime,
+				WebURL:          "http://127.0.0.1:1",
+			}))
+		})
+	})
+})
+
+var _ = Describe("Data#BuildStatus", func() {
+	buildStatus := func(statuses map[string]int) string {
+		return summary.Data{Statuses: statuses}.BuildStatus()
+	}
+
+	It("is failed when any job failed", func() {
+		Ω(buildStatus(map[string]int{"succeeded": 2, "errored": 1, "failed": 1})).Should(Equal("failed"))
+	})
+
+	It("is errored when any job errored or was aborted", func() {
+		Ω(buildStatus(map[string]int{"succeeded": 2, "errored": 1})).Should(Equal("errored"))
+		Ω(buildStatus(map[string]int{"succeeded": 2, "aborted": 1})).Should(Equal("errored"))
+	})
+
+	It("is succeeded when every finished job succeeded", func() {
+		Ω(buildStatus(map[string]int{"succeeded": 2, "paused_job": 1})).Should(Equal("succeeded"))
+	})
+
+	It("is pending when no job has finished", func() {
+		Ω(buildStatus(map[string]int{"pending": 2})).Should(Equal("pending"))
+	})
+})
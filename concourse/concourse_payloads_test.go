@@ -79,3 +79,161 @@ const pipelinesPayload = `[
     "team_name": "main"
   }
 ]`
+
+const groupedJobsPayload = `[
+  {
+    "id": 1,
+    "name": "unit",
+    "url": "/unit.job.url",
+    "groups": ["build"],
+    "finished_build": {
+      "id": 1,
+      "status": "succeeded"
+    }
+  },
+  {
+    "id": 2,
+    "name": "deploy",
+    "url": "/deploy.job.url",
+    "groups": ["deploy"],
+    "finished_build": {
+      "id": 2,
+      "status": "succeeded"
+    }
+  }
+]`
+
+const brokenResourcesPayload = `[
+  {
+    "name": "repo",
+    "type": "git",
+    "groups": ["build"],
+    "url": "/repo.url",
+    "failing_to_check": true,
+    "check_error": "fatal: repository not found"
+  },
+  {
+    "name": "image",
+    "type": "docker-image",
+    "groups": ["build", "deploy"],
+    "url": "/image.url"
+  }
+]`
+
+const pausedJobsPayload = `[
+  {
+    "id": 1,
+    "name": "testJob1",
+    "url": "/test1.job.url",
+    "paused": true,
+    "team_name": "main",
+    "finished_build": {
+      "id": 1,
+      "status": "succeeded"
+    }
+  },
+  {
+    "id": 2,
+    "name": "testJob2",
+    "url": "/test2.job.url",
+    "paused": true,
+    "team_name": "main",
+    "finished_build": {
+      "id": 2,
+      "status": "failed"
+    }
+  },
+  {
+    "id": 3,
+    "name": "testJob3",
+    "url": "/test3.job.url",
+    "paused": false,
+    "team_name": "main",
+    "finished_build": {
+      "id": 3,
+      "status": "succeeded"
+    }
+  },
+  {
+    "id": 4,
+    "name": "testJob4",
+    "url": "/test4.job.url",
+    "paused": false,
+    "team_name": "main",
+    "finished_build": {
+      "id": 4,
+      "status": "failed"
+    }
+  }
+]`
+
+const buildsJobsPayload = `[
+  {
+    "id": 1,
+    "name": "unit",
+    "url": "/teams/main/pipelines/test1/jobs/unit",
+    "groups": ["build"],
+    "finished_build": {
+      "id": 10,
+      "name": "12",
+      "status": "succeeded",
+      "start_time": 1504800000,
+      "end_time": 1504800090
+    }
+  },
+  {
+    "id": 2,
+    "name": "deploy",
+    "url": "/teams/main/pipelines/test1/jobs/deploy",
+    "groups": ["deploy"],
+    "paused": true,
+    "finished_build": {
+      "id": 11,
+      "name": "3",
+      "status": "failed",
+      "start_time": 1504800000,
+      "end_time": 1504803600
+    },
+    "next_build": {
+      "id": 12,
+      "name": "4",
+      "status": "started",
+      "start_time": 1504803700
+    }
+  }
+]`
+
+const transitionJobsPayload = `[
+  {
+    "id": 1,
+    "name": "unit",
+    "url": "/teams/main/pipelines/test1/jobs/unit",
+    "groups": ["build"],
+    "finished_build": {"id": 10, "name": "5", "status": "succeeded", "start_time": 1504799000, "end_time": 1504799100},
+    "transition_build": {"id": 8, "name": "3", "status": "succeeded", "start_time": 1504790000, "end_time": 1504790100}
+  },
+  {
+    "id": 2,
+    "name": "lint",
+    "url": "/teams/main/pipelines/test1/jobs/lint",
+    "groups": ["build"],
+    "finished_build": {"id": 11, "name": "7", "status": "succeeded", "start_time": 1504799000, "end_time": 1504799100},
+    "transition_build": {"id": 9, "name": "6", "status": "succeeded", "start_time": 1504793600, "end_time": 1504793700}
+  },
+  {
+    "id": 3,
+    "name": "deploy",
+    "url": "/teams/main/pipelines/test1/jobs/deploy",
+    "groups": ["deploy"],
+    "finished_build": {"id": 12, "name": "4", "status": "failed", "start_time": 1504803600, "end_time": 1504803700},
+    "transition_build": {"id": 7, "name": "2", "status": "failed", "start_time": 1504800000, "end_time": 1504800100}
+  },
+  {
+    "id": 4,
+    "name": "smoke",
+    "url": "/teams/main/pipelines/test1/jobs/smoke",
+    "groups": ["deploy"],
+    "finished_build": {"id": 13, "name": "9", "status": "errored", "start_time": 1504803600, "end_time": 1504803700},
+    "transition_build": {"id": 6, "name": "8", "status": "errored", "start_time": 1504780000, "end_time": 1504780100}
+  }
+]`
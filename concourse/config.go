@@ -0,0 +1,259 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+var defaultConfigWatchInterval = 5 * time.Second
+
+// configSettings are the settings of a config file with the environment variables
+// which override them, in the order SetupConfig takes them
+var configSettings = []struct {
+	key string
+	env string
+}{
+	{"refresh_interval", "REFRESH_INTERVAL"},
+	{"groups", "CS_GROUPS"},
+	{"hosts", "HOSTS"},
+	{"skip_ssl_validation", "SKIP_SSL_VALIDATION"},
+	{"team", "TEAM"},
+	{"fetch_concurrency", "FETCH_CONCURRENCY"},
+	{"credentials", "CREDENTIALS"},
+}
+
+// LoadConfig sets up a config object from a YAML or JSON config file, an environment
+// variable which is set overrides the file's setting. Without a path the config
+// comes from the environment alone, as it does with SetupConfig
+func LoadConfig(path string, getenv func(string) string) (*Config, error) {
+	var contents []byte
+	if path != "" {
+		var err error
+		if contents, err = ioutil.ReadFile(path); err != nil {
+			return &Config{}, err
+		}
+	}
+	return loadConfig(path, contents, getenv)
+}
+
+func loadConfig(path string, contents []byte, getenv func(string) string) (*Config, error) {
+	fileSettings := map[string]json.RawMessage{}
+	var errs ConfigErrors
+	if path != "" {
+		var err error
+		if fileSettings, err = parseConfigFile(path, contents); err != nil {
+			return &Config{}, ConfigErrors{{File: path, Message: err.Error()}}
+		}
+		errs = unknownSettings(path, fileSettings)
+	}
+
+	settings := make([]configSetting, len(configSettings))
+	for i, setting := range configSettings {
+		settings[i] = configSetting{key: setting.key, path: setting.env, value: getenv(setting.env)}
+		if settings[i].value == "" {
+			settings[i] = configSetting{key: setting.key, file: path, path: setting.key, value: settingString(fileSettings[setting.key])}
+		}
+	}
+
+	errs = append(errs, validateSettings(settings)...)
+	if len(errs) > 0 {
+		return &Config{}, errs
+	}
+	return SetupConfig(settings[0].value, settings[1].value, settings[2].value, settings[3].value, settings[4].value, settings[5].value, settings[6].value)
+}
+
+// Services are the notifiers, digest, history and group store, which are only read
+// from the environment at startup
+type Services struct {
+	Webhooks   []*Webhook
+	Slack      *Slack
+	Digest     *Digest
+	History    *History
+	GroupStore *GroupStore
+}
+
+// LoadServices parses WEBHOOKS, SLACK, DIGEST, HISTORY and GROUP_STORE, reporting
+// every problem found along with the environment variable which set it. The history
+// and group store databases aren't opened, so the settings can be checked without
+// them
+func LoadServices(getenv func(string) string) (*Services, error) {
+	var (
+		services Services
+		errs     ConfigErrors
+		err      error
+	)
+	check := func(env string, err error) {
+		if err != nil {
+			errs = append(errs, ConfigError{Path: env, Message: err.Error()})
+		}
+	}
+
+	services.Webhooks, err = SetupWebhooks(getenv("WEBHOOKS"))
+	check("WEBHOOKS", err)
+	services.Slack, err = SetupSlack(getenv("SLACK"))
+	check("SLACK", err)
+	services.Digest, err = SetupDigest(getenv("DIGEST"))
+	check("DIGEST", err)
+	services.History, err = ParseHistory(getenv("HISTORY"))
+	check("HISTORY", err)
+	services.GroupStore, err = ParseGroupStore(getenv("GROUP_STORE"))
+	check("GROUP_STORE", err)
+
+	if len(errs) > 0 {
+		return &Services{}, errs
+	}
+	return &services, nil
+}
+
+// parseConfigFile reads the settings of a config file as JSON, whichever format it
+// is written in, so hosts, groups and credentials are parsed just as they are from
+// the environment
+func parseConfigFile(path string, contents []byte) (map[string]json.RawMessage, error) {
+	var document interface{}
+	if strings.ToLower(filepath.Ext(path)) == ".json" {
+		if err := json.Unmarshal(contents, &document); err != nil {
+			return nil, jsonError(contents, err)
+		}
+	} else {
+		if err := yaml.Unmarshal(contents, &document); err != nil {
+			return nil, err
+		}
+		document = jsonValue(document)
+	}
+
+	settings := map[string]json.RawMessage{}
+	if document == nil {
+		return settings, nil
+	}
+	if _, ok := document.(map[string]interface{}); !ok {
+		return nil, errors.New("the file must be a map of settings")
+	}
+	converted, err := json.Marshal(document)
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(converted, &settings)
+	return settings, err
+}
+
+// jsonValue converts a value decoded from YAML into one encoding/json can marshal,
+// YAML maps aren't keyed by strings
+func jsonValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		converted := map[string]interface{}{}
+		for k, v := range value {
+			converted[fmt.Sprint(k)] = jsonValue(v)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(value))
+		for i, v := range value {
+			converted[i] = jsonValue(v)
+		}
+		return converted
+	default:
+		return value
+	}
+}
+
+// settingString is a setting in the form its environment variable takes, strings
+// are unquoted and everything else is left as JSON
+func settingString(setting json.RawMessage) string {
+	if len(setting) == 0 || string(setting) == "null" {
+		return ""
+	}
+	var value string
+	if err := json.Unmarshal(setting, &value); err == nil {
+		return value
+	}
+	return string(setting)
+}
+
+// ConfigWatcher reloads a config file into a server whenever its contents change. A
+// file which fails to load is reported and the running config is kept until the
+// file is fixed
+type ConfigWatcher struct {
+	Path     string
+	Interval time.Duration
+	Server   *Server
+	Getenv   func(string) string
+
+	contents  []byte
+	stop      chan struct{}
+	waitGroup sync.WaitGroup
+}
+
+// NewConfigWatcher creates a watcher for the config file at path which the server
+// was started with
+func NewConfigWatcher(path string, server *Server) *ConfigWatcher {
+	contents, _ := ioutil.ReadFile(path)
+	return &ConfigWatcher{
+		Path:     path,
+		Interval: defaultConfigWatchInterval,
+		Server:   server,
+		Getenv:   os.Getenv,
+		contents: contents,
+	}
+}
+
+// Start checks the config file for changes on an interval until stopped
+func (w *ConfigWatcher) Start() {
+	w.stop = make(chan struct{})
+	w.waitGroup.Add(1)
+	go func() {
+		defer w.waitGroup.Done()
+
+		ticker := time.NewTicker(w.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.stop:
+				return
+			case <-ticker.C:
+				if err := w.Check(); err != nil {
+					fmt.Println(err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// Stop halts watching
+func (w *ConfigWatcher) Stop() {
+	close(w.stop)
+	w.waitGroup.Wait()
+}
+
+// Check reloads the config file into the server if it has changed since it was
+// last checked. The file is compared by its contents as editors and config map
+// volumes replace files in ways which don't always change their modification time
+func (w *ConfigWatcher) Check() error {
+	contents, err := ioutil.ReadFile(w.Path)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(contents, w.contents) {
+		return nil
+	}
+	w.contents = contents
+
+	config, err := loadConfig(w.Path, contents, w.Getenv)
+	if err != nil {
+		return fmt.Errorf("config not reloaded: %s", err.Error())
+	}
+	w.Server.Reload(config)
+	fmt.Printf("reloaded config from %s\n", w.Path)
+	return nil
+}
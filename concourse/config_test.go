@@ -0,0 +1,222 @@
+package summary_test
+
+import (
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+const yamlConfig = `
+refresh_interval: 60
+team: ops
+skip_ssl_validation: true
+hosts:
+  - ci.example.com
+  - fqdn: secure.example.com
+    teams: [alpha, beta]
+groups:
+  - group: payments
+    hosts:
+      - fqdn: ci.example.com
+        pipelines:
+          - name: release
+            groups: [deploy]
+credentials:
+  ci.example.com:
+    token: secret
+`
+
+var _ = Describe("Config files", func() {
+	var (
+		dir string
+		env map[string]string
+	)
+
+	getenv := func(name string) string {
+		return env[name]
+	}
+
+	writeConfig := func(name, contents string) string {
+		path := filepath.Join(dir, name)
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "config")
+		Expect(err).ToNot(HaveOccurred())
+		env = map[string]string{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	Describe("LoadConfig", func() {
+		It("reads hosts, groups, credentials and settings from YAML", func() {
+			config, err := summary.LoadConfig(writeConfig("config.yml", yamlConfig), getenv)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.RefreshInterval).To(Equal(60))
+			Expect(config.Team).To(Equal("ops"))
+			Expect(config.SkipSSLValidation).To(BeTrue())
+			Expect(config.FetchConcurrency).To(Equal(4))
+			Expect(config.Hosts).To(HaveLen(2))
+			Expect(config.Hosts[0].FQDN).To(Equal("ci.example.com"))
+			Expect(config.Hosts[1].Teams).To(Equal([]string{"alpha", "beta"}))
+			Expect(config.CSGroups).To(Equal(summary.CSGroups{
+				{Group: "payments", Hosts: []summary.Host{{FQDN: "ci.example.com", Pipelines: []summary.Pipeline{{Name: "release", Groups: []string{"deploy"}}}}}},
+			}))
+			Expect(config.Credentials["ci.example.com"].Token).To(Equal("secret"))
+		})
+
+		It("reads JSON", func() {
+			config, err := summary.LoadConfig(writeConfig("config.json", "{\n\t\"refresh_interval\": 15,\n\t\"hosts\": [\"ci.example.com\"]\n}"), getenv)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.RefreshInterval).To(Equal(15))
+			Expect(config.Hosts[0].FQDN).To(Equal("ci.example.com"))
+		})
+
+		It("lets environment variables override the file's settings", func() {
+			env["TEAM"] = "main"
+			env["CS_GROUPS"] = `[{"group": "overridden"}]`
+
+			config, err := summary.LoadConfig(writeConfig("config.yml", yamlConfig), getenv)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Team).To(Equal("main"))
+			Expect(config.CSGroups).To(Equal(summary.CSGroups{{Group: "overridden"}}))
+			Expect(config.RefreshInterval).To(Equal(60))
+		})
+
+		It("reads the environment alone without a file", func() {
+			env["HOSTS"] = `["ci.example.com"]`
+
+			config, err := summary.LoadConfig("", getenv)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(config.Hosts[0].FQDN).To(Equal("ci.example.com"))
+			Expect(config.RefreshInterval).To(Equal(30))
+		})
+
+		It("returns an error naming the file when it can't be parsed", func() {
+			path := writeConfig("config.yml", "hosts: [ci.example.com")
+			_, err := summary.LoadConfig(path, getenv)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix(path + ": yaml: line 1:"))
+		})
+	})
+
+	Describe("LoadServices", func() {
+		It("parses the settings only read from the environment without opening databases", func() {
+			env["SLACK"] = `{"webhook_url": "https://hooks.slack.com/services/T/B/X"}`
+			env["HISTORY"] = `{"path": "` + filepath.Join(dir, "missing", "history.db") + `"}`
+
+			services, err := summary.LoadServices(getenv)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(services.Slack.WebhookURL).To(Equal("https://hooks.slack.com/services/T/B/X"))
+			Expect(services.History.Path).To(Equal(filepath.Join(dir, "missing", "history.db")))
+			Expect(services.Digest).To(BeNil())
+			Expect(services.GroupStore).To(BeNil())
+		})
+
+		It("reports every problem with the environment variable which set it", func() {
+			env["WEBHOOKS"] = `[{"url": "ftp://example.com"}]`
+			env["SLACK"] = `{"webhook_url": 5}`
+			env["DIGEST"] = `{"to": ["team@example.com"]}`
+			env["HISTORY"] = `{"retention": "1d"}`
+			env["GROUP_STORE"] = `{"path": "groups.db"}`
+
+			_, err := summary.LoadServices(getenv)
+			Expect(err).To(HaveOccurred())
+			errs := err.(summary.ConfigErrors)
+			Expect(errs).To(HaveLen(5))
+			Expect(errs[0].Path).To(Equal("WEBHOOKS"))
+			Expect(errs[1].Error()).To(HavePrefix("SLACK: json: cannot unmarshal number"))
+			Expect(errs[2].Error()).To(Equal("DIGEST: digest smtp host is required"))
+			Expect(errs[3].Error()).To(Equal("HISTORY: history path is required"))
+			Expect(errs[4].Error()).To(Equal("GROUP_STORE: group store token is required"))
+		})
+	})
+
+	Describe("ConfigWatcher", func() {
+		var (
+			path    string
+			server  *summary.Server
+			watcher *summary.ConfigWatcher
+			router  http.Handler
+		)
+
+		index := func() string {
+			mockRecorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "http://example.com/", nil)
+			router.ServeHTTP(mockRecorder, req)
+			return stringMinifier(mockRecorder.Body.String())
+		}
+
+		BeforeEach(func() {
+			path = writeConfig("config.yml", yamlConfig)
+			config, err := summary.LoadConfig(path, getenv)
+			Expect(err).ToNot(HaveOccurred())
+			config.Templates = template.Must(template.ParseGlob("../templates/*"))
+
+			server = summary.CreateServer(config)
+			router = server.Start()
+			watcher = summary.NewConfigWatcher(path, server)
+			watcher.Getenv = getenv
+		})
+
+		It("swaps a changed file into the running server", func() {
+			Expect(index()).To(ContainSubstring(`<ahref="/group/payments">payments</a>`))
+
+			writeConfig("config.yml", "groups: [{group: platform}]")
+			Expect(watcher.Check()).To(Succeed())
+			Expect(index()).To(ContainSubstring(`<ahref="/group/platform">platform</a>`))
+			Expect(index()).ToNot(ContainSubstring("payments"))
+		})
+
+		It("carries the cache over, fetching with the new config", func() {
+			cache := server.Config.Cache
+
+			var reloaded *summary.Config
+			server.OnReload(func(config *summary.Config) {
+				reloaded = config
+			})
+
+			writeConfig("config.yml", "refresh_interval: 10")
+			Expect(watcher.Check()).To(Succeed())
+			Expect(reloaded.RefreshInterval).To(Equal(10))
+			Expect(reloaded.Cache).To(BeIdenticalTo(cache))
+			Expect(cache.MaxAge).To(Equal(20 * time.Second))
+			Expect(reloaded.Templates).ToNot(BeNil())
+		})
+
+		It("keeps the running config when the file fails to load", func() {
+			writeConfig("config.yml", "refresh_interval: soon")
+			err := watcher.Check()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("config not reloaded:"))
+			Expect(index()).To(ContainSubstring("payments"))
+
+			// an unchanged file isn't reported again
+			Expect(watcher.Check()).To(Succeed())
+		})
+
+		It("does nothing while the file is unchanged", func() {
+			reloads := 0
+			server.OnReload(func(*summary.Config) {
+				reloads++
+			})
+
+			Expect(watcher.Check()).To(Succeed())
+			Expect(reloads).To(Equal(0))
+		})
+	})
+})
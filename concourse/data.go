@@ -1,87 +1,188 @@
 package summary
 
 import (
-	"crypto/tls"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
+	"net/url"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	"github.com/concourse/atc"
 	"github.com/concourse/go-concourse/concourse"
 )
 
 // Data concourse data structure
 type Data struct {
-	Pipeline       string
-	Group          string
-	URL            string `json:"pipeline_url"`
-	Running        bool
-	Paused         bool
-	BrokenResource bool
-	Statuses       map[string]int
+	Host            string           `json:"host"`
+	Team            string           `json:"team"`
+	Pipeline        string           `json:"pipeline"`
+	Group           string           `json:"group"`
+	URL             string           `json:"pipeline_url"`
+	Running         bool             `json:"running"`
+	Paused          bool             `json:"paused"`
+	Public          bool             `json:"public"`
+	BrokenResource  bool             `json:"broken_resource"`
+	BrokenResources []BrokenResource `json:"broken_resources"`
+	Statuses        map[string]int   `json:"statuses"`
+	Jobs            []JobData        `json:"-"`
+	Since           time.Time        `json:"-"`
+	LastGreen       time.Time        `json:"-"`
 }
 
 // GroupData a grouping structure for Data
 type GroupData struct {
-	Host     string
-	Statuses []Data
+	Host          string
+	Statuses      []Data
+	Error         string
+	LastFetched   time.Time
+	LastAttempted time.Time
 }
 
+// Tiles returns the data of the group as summary tiles
+func (g GroupData) Tiles() []tile {
+	return tiles(g.Statuses)
+}
+
+// Key identifies the error tile shown for the host of the group
+func (g GroupData) Key() string {
+	return fmt.Sprintf("%s:error", g.Host)
+}
+
+// tile is a single pipeline group shown on a summary page
+type tile struct {
+	Data
+	MultiTeam bool
+}
+
+func tiles(data []Data) []tile {
+	multiTeam := multiTeam(data)
+	tiles := make([]tile, len(data))
+	for i, datum := range data {
+		tiles[i] = tile{Data: datum, MultiTeam: multiTeam}
+	}
+	return tiles
+}
+
+// Key identifies the tile of a pipeline group on a summary page
+func (d Data) Key() string {
+	return fmt.Sprintf("%s:%s:%s:%s", d.Host, d.Team, d.Pipeline, d.Group)
+}
+
+func multiTeam(data []Data) bool {
+	for _, datum := range data {
+		if datum.Team != data[0].Team {
+			return true
+		}
+	}
+	return false
+}
+
+// LastFetchedAt formats the time data was last successfully fetched for the host
+func (g GroupData) LastFetchedAt() string {
+	if g.LastFetched.IsZero() {
+		return "never"
+	}
+	return g.LastFetched.Format("2006-01-02 15:04:05 -0700")
+}
+
+// filterData returns the data selected by any of the pipeline entries, each datum
+// once however many entries select it. Without entries every datum is selected
 func filterData(data []Data, pipelines []Pipeline) []Data {
 	var filteredData []Data
 	for _, datum := range data {
-		if len(pipelines) == 0 || pipelines == nil {
+		if len(pipelines) == 0 {
 			filteredData = append(filteredData, datum)
+			continue
 		}
 		for _, pipeline := range pipelines {
-			if datum.Pipeline != pipeline.Name {
-				continue
-			}
-			if len(pipeline.Groups) == 0 || pipeline.Groups == nil {
+			if pipeline.matches(datum) && pipeline.matchesGroup(datum) {
 				filteredData = append(filteredData, datum)
-			} else {
-				for _, group := range pipeline.Groups {
-					if group == "all" && datum.Group == "" {
-						filteredData = append(filteredData, datum)
-					}
-					if group == datum.Group {
-						filteredData = append(filteredData, datum)
-					}
-				}
+				break
 			}
 		}
 	}
 	return filteredData
 }
 
-func getData(host string, config *Config) ([]Data, error) {
-	uri := fmt.Sprintf("%s://%s", config.Protocol, host)
-	httpClient := createHTTPClient(config)
-	client := concourse.NewClient(uri, httpClient, false)
-	team := client.Team(config.Team)
-	pipelines, err := team.ListPipelines()
+// matches reports whether a pipeline entry refers to the pipeline of datum, an entry
+// without a name matches every pipeline of its team
+func (p Pipeline) matches(datum Data) bool {
+	if p.Team != "" && p.Team != datum.Team {
+		return false
+	}
+	if p.Name == "" && p.Team == "" {
+		return false
+	}
+	if p.Name != "" && !matchPattern(p.Name, datum.Pipeline) {
+		return false
+	}
+	return !matchAnyPattern(p.Exclude, datum.Pipeline)
+}
+
+// matchesGroup reports whether a pipeline entry shows the pipeline group of datum.
+// An entry without groups shows them all, and "all" shows a pipeline without groups
+// unless it has a group called all
+func (p Pipeline) matchesGroup(datum Data) bool {
+	if matchAnyPattern(p.ExcludeGroups, datum.Group) {
+		return false
+	}
+	if len(p.Groups) == 0 {
+		return true
+	}
+	for _, group := range p.Groups {
+		if group == "all" && datum.Group == "" {
+			return true
+		}
+	}
+	return matchAnyPattern(p.Groups, datum.Group)
+}
+
+func getData(host Host, config *Config) ([]Data, error) {
+	uri := host.URL()
+	teams, err := config.teams(host)
 	if err != nil {
 		return []Data{}, err
 	}
-	data := map[string]Data{}
-	for _, pipeline := range pipelines {
-		jobs, err := team.ListJobs(pipeline.Name)
+	var pipelines []teamPipeline
+	for _, team := range teams {
+		teamPipelines, err := team.team.ListPipelines()
 		if err != nil {
 			return []Data{}, err
 		}
-		for _, job := range jobs {
+		for _, pipeline := range teamPipelines {
+			team.pipeline = pipeline
+			pipelines = append(pipelines, team)
+		}
+	}
+	details, err := fetchPipelines(pipelines, host.FetchConcurrency)
+	if err != nil {
+		return []Data{}, err
+	}
+	data := map[string]Data{}
+	for i, teamPipeline := range pipelines {
+		pipeline := teamPipeline.pipeline
+		for _, job := range details[i].jobs {
 			groups := job.Groups
 			if len(groups) == 0 {
 				groups = []string{""}
 			}
 			for _, group := range groups {
-				key := fmt.Sprintf("%s:%s", pipeline.Name, group)
+				key := fmt.Sprintf("%s:%s:%s", teamPipeline.team.Name(), pipeline.Name, group)
 				datum := data[key]
 				if datum.Statuses == nil {
 					datum.Statuses = map[string]int{}
+					datum.Host = host.FQDN
+					datum.Team = teamPipeline.team.Name()
 					datum.Pipeline = pipeline.Name
 					datum.Group = group
 					datum.Paused = pipeline.Paused
+					datum.Public = pipeline.Public
+					datum.BrokenResources = brokenResources(details[i].resources, group)
+					datum.BrokenResource = len(datum.BrokenResources) > 0
 					if group == "" {
 						datum.URL = fmt.Sprintf("%s%s", uri, pipeline.URL)
 					} else {
@@ -91,17 +192,16 @@ func getData(host string, config *Config) ([]Data, error) {
 				if !datum.Running {
 					datum.Running = (job.NextBuild != nil)
 				}
-				if job.FinishedBuild != nil {
-					datum.Statuses[job.FinishedBuild.Status]++
-				} else {
-					datum.Statuses["pending"]++
-				}
+				datum.Statuses[jobStatus(job)]++
+				datum.Jobs = append(datum.Jobs, newJobData(host, teamPipeline.team.Name(), pipeline.Name, job))
 				data[key] = datum
 			}
 		}
 	}
+	now := time.Now()
 	values := make([]Data, 0, len(data))
 	for _, value := range data {
+		value.Since, value.LastGreen = jobsSince(value, now)
 		values = append(values, value)
 	}
 
@@ -110,6 +210,213 @@ func getData(host string, config *Config) ([]Data, error) {
 	return values, nil
 }
 
+// jobStatus is the status bucket a job is counted in, paused jobs are counted as
+// paused regardless of their last build
+func jobStatus(job atc.Job) string {
+	if job.Paused {
+		return "paused_job"
+	}
+	if job.FinishedBuild != nil {
+		return job.FinishedBuild.Status
+	}
+	return "pending"
+}
+
+type teamPipeline struct {
+	client   concourse.Client
+	team     concourse.Team
+	pipeline atc.Pipeline
+}
+
+// teams returns a client for each concourse team summarised on a host, a team of
+// "*" expands to every team on the host
+func (config *Config) teams(host Host) ([]teamPipeline, error) {
+	names := host.Teams
+	if len(names) == 0 {
+		names = []string{host.Team}
+	}
+
+	for _, name := range names {
+		if name != allTeams {
+			continue
+		}
+
+		client, err := config.client(host, defaultTeam)
+		if err != nil {
+			return nil, err
+		}
+		atcTeams, err := client.ListTeams()
+		if err != nil {
+			return nil, err
+		}
+		names = nil
+		for _, atcTeam := range atcTeams {
+			names = append(names, atcTeam.Name)
+		}
+		break
+	}
+
+	var teams []teamPipeline
+	for _, name := range names {
+		client, err := config.client(host, name)
+		if err != nil {
+			return nil, err
+		}
+		teams = append(teams, teamPipeline{client: client, team: client.Team(name)})
+	}
+	return teams, nil
+}
+
+// pipelineDetails are the jobs and resources of a pipeline
+type pipelineDetails struct {
+	jobs      []atc.Job
+	resources []atc.Resource
+}
+
+// fetchPipelines fetches the jobs and resources of every pipeline using at most
+// concurrency workers at a time, results are returned in the same order as pipelines
+func fetchPipelines(pipelines []teamPipeline, concurrency int) ([]pipelineDetails, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	details := make([]pipelineDetails, len(pipelines))
+	errs := make([]error, len(pipelines))
+	indexes := make(chan int)
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for index := range indexes {
+				details[index], errs[index] = pipelines[index].details()
+			}
+		}()
+	}
+
+	for index := range pipelines {
+		indexes <- index
+	}
+	close(indexes)
+	waitGroup.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return details, nil
+}
+
+func (p teamPipeline) details() (pipelineDetails, error) {
+	jobs, err := p.team.ListJobs(p.pipeline.Name)
+	if err != nil {
+		return pipelineDetails{}, err
+	}
+	resources, err := listResources(p.client, p.team.Name(), p.pipeline.Name)
+	if err != nil {
+		return pipelineDetails{}, err
+	}
+	return pipelineDetails{jobs: jobs, resources: resources}, nil
+}
+
+// errorClass describes the kind of failure encountered collecting data from a host
+func errorClass(err error) string {
+	if urlErr, ok := err.(*url.Error); ok {
+		if urlErr.Timeout() {
+			return "timeout"
+		}
+		err = urlErr.Err
+	}
+
+	switch err {
+	case concourse.ErrUnauthorized:
+		return "unauthorized"
+	case concourse.ErrForbidden:
+		return "forbidden"
+	}
+
+	switch e := err.(type) {
+	case *json.SyntaxError, *json.UnmarshalTypeError:
+		return "invalid response"
+	case net.Error:
+		if e.Timeout() {
+			return "timeout"
+		}
+		return "connection error"
+	}
+
+	if strings.HasPrefix(err.Error(), "Unexpected Response") {
+		return "unexpected response"
+	}
+	return "error"
+}
+
+// BuildStatus is the aggregate result of the latest builds in a pipeline group,
+// one of failed, errored, succeeded or pending when no job has finished a build
+func (d Data) BuildStatus() string {
+	switch {
+	case d.Statuses["failed"] > 0:
+		return "failed"
+	case d.Statuses["errored"] > 0 || d.Statuses["aborted"] > 0:
+		return "errored"
+	case d.Statuses["succeeded"] > 0:
+		return "succeeded"
+	}
+	return "pending"
+}
+
+// State is the overall state of a pipeline group, paused when the pipeline is
+// paused and otherwise its BuildStatus
+func (d Data) State() string {
+	if d.Paused {
+		return "paused"
+	}
+	return d.BuildStatus()
+}
+
+// sinceStatuses are the job statuses which put a pipeline group in each state
+var sinceStatuses = map[string][]string{
+	"failed":    {"failed"},
+	"errored":   {"errored", "aborted"},
+	"succeeded": {"succeeded"},
+}
+
+// jobsSince works out from the transition builds of its jobs when a pipeline group
+// entered its current state and when it was last green. A red pipeline group became
+// red when its first job did and a green one became green when its last job did,
+// times are zero when concourse doesn't say
+func jobsSince(datum Data, now time.Time) (time.Time, time.Time) {
+	state := transitionState(datum)
+
+	latest := state == "succeeded"
+
+	var since time.Time
+	for _, job := range datum.Jobs {
+		if job.Paused || job.TransitionAt.IsZero() || !contains(sinceStatuses[state], job.Status) {
+			continue
+		}
+		if since.IsZero() || (latest && job.TransitionAt.After(since)) || (!latest && job.TransitionAt.Before(since)) {
+			since = job.TransitionAt
+		}
+	}
+
+	if state == "succeeded" {
+		return since, now
+	}
+	return since, time.Time{}
+}
+
+// RedFor is how long a failed or errored pipeline group has been red, empty when
+// it isn't red or it isn't known
+func (d Data) RedFor() string {
+	if d.Since.IsZero() || (d.State() != "failed" && d.State() != "errored") {
+		return ""
+	}
+	return duration(time.Since(d.Since))
+}
+
 // Percent calculate the a percentage value for a particular status from data statuses
 func (d Data) Percent(status string) int {
 	if len(d.Statuses) == 0 {
@@ -126,16 +433,62 @@ func mapValueSum(sourceData map[string]int) int {
 	return sum
 }
 
-func createHTTPClient(config *Config) *http.Client {
+func createHTTPClient(host Host) (*http.Client, error) {
+	tlsConfig, err := host.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+
 	client := &http.Client{
 		Transport: &http.Transport{
-			MaxIdleConnsPerHost: 2,
-			TLSClientConfig:     &tls.Config{InsecureSkipVerify: config.SkipSSLValidation},
+			MaxIdleConnsPerHost: host.FetchConcurrency,
+			TLSClientConfig:     tlsConfig,
 		},
 		Timeout: time.Duration(30) * time.Second,
 	}
 
-	return client
+	return client, nil
+}
+
+// httpClient returns the client used for a host, clients are kept between fetches
+// so that connections and auth tokens are reused
+func (config *Config) httpClient(host Host, team string) (*http.Client, error) {
+	caches := config.sharedCaches()
+	caches.httpClientsMutex.Lock()
+	defer caches.httpClientsMutex.Unlock()
+
+	// tokens are scoped to a team so each team gets its own client
+	key := fmt.Sprintf("%s/%s", host.FQDN, team)
+	if client, ok := caches.httpClients[key]; ok {
+		return client, nil
+	}
+
+	client, err := createHTTPClient(host)
+	if err != nil {
+		return nil, err
+	}
+	if host.Credentials != nil && !host.Credentials.empty() {
+		client.Transport = &authTransport{
+			base:        client.Transport,
+			credentials: *host.Credentials,
+			uri:         host.URL(),
+			team:        team,
+		}
+	}
+
+	if caches.httpClients == nil {
+		caches.httpClients = map[string]*http.Client{}
+	}
+	caches.httpClients[key] = client
+	return client, nil
+}
+
+func (config *Config) client(host Host, team string) (concourse.Client, error) {
+	httpClient, err := config.httpClient(host, team)
+	if err != nil {
+		return nil, err
+	}
+	return concourse.NewClient(host.URL(), httpClient, false), nil
 }
 
 type byData []Data
@@ -149,6 +502,10 @@ func (r byData) Swap(i, j int) {
 }
 
 func (r byData) Less(i, j int) bool {
+	if r[i].Team != r[j].Team {
+		return r[i].Team < r[j].Team
+	}
+
 	first := fmt.Sprintf("%s%s", r[i].Pipeline, r[i].Group)
 	second := fmt.Sprintf("%s%s", r[j].Pipeline, r[j].Group)
 
@@ -0,0 +1,382 @@
+package summary_test
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/gorilla/mux"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+type concurrencyTracker struct {
+	mutex    sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (c *concurrencyTracker) start() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.inFlight++
+	if c.inFlight > c.max {
+		c.max = c.inFlight
+	}
+}
+
+func (c *concurrencyTracker) finish() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.inFlight--
+}
+
+func (c *concurrencyTracker) Max() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.max
+}
+
+var _ = Describe("data collection", func() {
+	var (
+		config       *summary.Config
+		slowServer   *httptest.Server
+		tracker      *concurrencyTracker
+		pipelineList []string
+	)
+
+	BeforeEach(func() {
+		tracker = &concurrencyTracker{}
+		pipelineList = nil
+		for i := 20; i > 0; i-- {
+			pipelineList = append(pipelineList, fmt.Sprintf(`{"name": "pipeline%02d", "url": "/pipeline%02d.url"}`, i, i))
+		}
+
+		slowServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.HasSuffix(r.URL.Path, "/pipelines") {
+				fmt.Fprintf(w, "[%s]", strings.Join(pipelineList, ","))
+				return
+			}
+
+			tracker.start()
+			defer tracker.finish()
+			time.Sleep(10 * time.Millisecond)
+			fmt.Fprint(w, `[{"name": "job", "finished_build": {"status": "succeeded"}}]`)
+		}))
+
+		var err error
+		config, err = summary.SetupConfig("", "", "", "", "", "3", "")
+		Ω(err).Should(BeNil())
+		config.Protocol = "http"
+	})
+
+	AfterEach(func() {
+		slowServer.Close()
+	})
+
+	It("fetches jobs for every pipeline in sorted order", func() {
+		snapshot := config.Cache.Get(Host(slowServer))
+		Ω(snapshot.Err).Should(BeNil())
+		Ω(snapshot.Data).Should(HaveLen(20))
+		for i, datum := range snapshot.Data {
+			Ω(datum.Pipeline).Should(Equal(fmt.Sprintf("pipeline%02d", i+1)))
+			Ω(datum.Statuses).Should(Equal(map[string]int{"succeeded": 1}))
+		}
+	})
+
+	It("fetches jobs concurrently without exceeding the fetch concurrency", func() {
+		config.Cache.Get(Host(slowServer))
+		Ω(tracker.Max()).Should(BeNumerically(">", 1))
+		Ω(tracker.Max()).Should(BeNumerically("<=", 3))
+	})
+})
+
+var _ = Describe("per-host connection settings", func() {
+	var (
+		hostServer *httptest.Server
+		teams      []string
+		authHeader string
+		mutex      sync.Mutex
+	)
+
+	BeforeEach(func() {
+		teams = nil
+		hostServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			teams = append(teams, strings.Split(r.URL.Path, "/")[4])
+			authHeader = r.Header.Get("Authorization")
+			fmt.Fprint(w, "[]")
+		}))
+	})
+
+	AfterEach(func() {
+		hostServer.Close()
+	})
+
+	It("uses the protocol, port, team and credentials configured on the host", func() {
+		address := strings.Split(Host(hostServer), ":")
+		config, err := summary.SetupConfig("", "", fmt.Sprintf(`[{"fqdn": "%s", "protocol": "http", "port": %s, "team": "dev", "credentials": {"token": "abc"}}]`, address[0], address[1]), "", "", "", "")
+		Ω(err).Should(BeNil())
+
+		snapshot := config.Cache.Get(address[0])
+		Ω(snapshot.Err).Should(BeNil())
+		Ω(teams).Should(Equal([]string{"dev"}))
+		Ω(authHeader).Should(Equal("Bearer abc"))
+	})
+
+	It("falls back to the config wide settings for hosts without their own", func() {
+		config, err := summary.SetupConfig("", "", fmt.Sprintf(`["%s"]`, Host(hostServer)), "", "ops", "", "")
+		Ω(err).Should(BeNil())
+		config.Protocol = "http"
+
+		snapshot := config.Cache.Get(Host(hostServer))
+		Ω(snapshot.Err).Should(BeNil())
+		Ω(teams).Should(Equal([]string{"ops"}))
+		Ω(authHeader).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("multiple teams", func() {
+	var (
+		teamServer *httptest.Server
+		config     *summary.Config
+		hostsJSON  string
+		groupsJSON string
+	)
+
+	BeforeEach(func() {
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/teams", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `[{"name": "alpha"}, {"name": "beta"}]`)
+		})
+		router.HandleFunc("/api/v1/teams/{team}/pipelines", func(w http.ResponseWriter, r *http.Request) {
+			team := mux.Vars(r)["team"]
+			fmt.Fprintf(w, `[{"name": "deploy", "url": "/teams/%s/pipelines/deploy", "team_name": "%s"}]`, team, team)
+		})
+		router.HandleFunc("/api/v1/teams/{team}/pipelines/deploy/jobs", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `[{"name": "job", "finished_build": {"status": "succeeded"}}]`)
+		})
+		router.HandleFunc("/api/v1/teams/{team}/pipelines/deploy/resources", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "[]")
+		})
+		teamServer = httptest.NewServer(router)
+		groupsJSON = ""
+	})
+
+	AfterEach(func() {
+		teamServer.Close()
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		config, err = summary.SetupConfig("", groupsJSON, hostsJSON, "", "", "", "")
+		Ω(err).Should(BeNil())
+		config.Protocol = "http"
+		config.Templates = template.Must(template.ParseGlob("../templates/*"))
+	})
+
+	Context("when a host lists its teams", func() {
+		BeforeEach(func() {
+			hostsJSON = fmt.Sprintf(`[{"fqdn": "%s", "teams": ["beta", "alpha"]}]`, Host(teamServer))
+		})
+
+		It("collects data for every team, labelled by team", func() {
+			snapshot := config.Cache.Get(Host(teamServer))
+			Ω(snapshot.Err).Should(BeNil())
+			Ω(snapshot.Data).Should(HaveLen(2))
+			Ω(snapshot.Data[0].Team).Should(Equal("alpha"))
+			Ω(snapshot.Data[0].URL).Should(HaveSuffix("/teams/alpha/pipelines/deploy"))
+			Ω(snapshot.Data[1].Team).Should(Equal("beta"))
+		})
+
+		It("labels each tile with its team", func() {
+			mockRecorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("http://example.com/host/%s", Host(teamServer)), nil)
+			Router(config).ServeHTTP(mockRecorder, req)
+
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`<span class="team"><span>alpha</span></span>`))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`<span class="team"><span>beta</span></span>`))
+		})
+	})
+
+	Context("when a host summarises all teams", func() {
+		BeforeEach(func() {
+			hostsJSON = fmt.Sprintf(`[{"fqdn": "%s", "teams": ["*"]}]`, Host(teamServer))
+		})
+
+		It("collects data for every team on the host", func() {
+			snapshot := config.Cache.Get(Host(teamServer))
+			Ω(snapshot.Err).Should(BeNil())
+			Ω(snapshot.Data).Should(HaveLen(2))
+			Ω(snapshot.Data[0].Team).Should(Equal("alpha"))
+			Ω(snapshot.Data[1].Team).Should(Equal("beta"))
+		})
+
+		Context("and a group pins a pipeline to a team", func() {
+			BeforeEach(func() {
+				groupsJSON = fmt.Sprintf(`[{"group": "test", "hosts": [{"fqdn": "%s", "pipelines": [{"team": "beta", "name": "deploy"}]}]}]`, Host(teamServer))
+			})
+
+			It("only shows the pipeline from that team", func() {
+				mockRecorder := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "http://example.com/group/test", nil)
+				Router(config).ServeHTTP(mockRecorder, req)
+
+				Ω(mockRecorder.Code).Should(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring("/pipeline/deploy?team=beta"))
+				Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring("/pipeline/deploy?team=alpha"))
+				Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring(`<span class="team">`))
+			})
+		})
+
+		Context("and a group pins a whole team", func() {
+			BeforeEach(func() {
+				groupsJSON = fmt.Sprintf(`[{"group": "test", "hosts": [{"fqdn": "%s", "pipelines": [{"team": "alpha"}]}]}]`, Host(teamServer))
+			})
+
+			It("shows every pipeline from that team", func() {
+				mockRecorder := httptest.NewRecorder()
+				req, _ := http.NewRequest("GET", "http://example.com/group/test", nil)
+				Router(config).ServeHTTP(mockRecorder, req)
+
+				Ω(mockRecorder.Code).Should(Equal(200))
+				Ω(mockRecorder.Body.String()).Should(ContainSubstring("/pipeline/deploy?team=alpha"))
+				Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring("/pipeline/deploy?team=beta"))
+			})
+		})
+	})
+})
+
+var _ = Describe("broken resources", func() {
+	var config *summary.Config
+
+	BeforeEach(func() {
+		setupMultiple([]MockRoute{
+			{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/jobs", groupedJobsPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/resources", brokenResourcesPayload, 200, "", nil},
+		})
+		config = &summary.Config{
+			Protocol:  "http",
+			Templates: template.Must(template.ParseGlob("../templates/*")),
+		}
+	})
+
+	AfterEach(func() {
+		teardown()
+	})
+
+	It("marks the pipeline groups containing a resource that is failing to check", func() {
+		mockRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://example.com/host/%s", Host(server)), nil)
+		Router(config).ServeHTTP(mockRecorder, req)
+
+		Ω(mockRecorder.Code).Should(Equal(200))
+		body := stringMinifier(mockRecorder.Body.String())
+		Ω(body).Should(ContainSubstring(stringMinifier(`<div class="paused broken_resource" title="repo: fatal: repository not found&#10;"></div>
+			<div class="inner">
+				<span class="test1"><span>test1</span></span>
+				<span class="build"><span>build</span></span>`)))
+		Ω(body).ShouldNot(ContainSubstring(stringMinifier(`<div class="paused broken_resource" title="repo: fatal: repository not found&#10;"></div>
+			<div class="inner">
+				<span class="test1"><span>test1</span></span>
+				<span class="deploy"><span>deploy</span></span>`)))
+	})
+
+	It("lists the failing resources and their check errors", func() {
+		config, err := summary.SetupConfig("", "", "", "", "", "", "")
+		Ω(err).Should(BeNil())
+		config.Protocol = "http"
+		config.Team = ""
+
+		snapshot := config.Cache.Get(Host(server))
+		Ω(snapshot.Err).Should(BeNil())
+		Ω(snapshot.Data).Should(HaveLen(2))
+		Ω(snapshot.Data[0].Group).Should(Equal("build"))
+		Ω(snapshot.Data[0].BrokenResource).Should(BeTrue())
+		Ω(snapshot.Data[0].BrokenResources).Should(Equal([]summary.BrokenResource{
+			{Name: "repo", CheckError: "fatal: repository not found"},
+		}))
+		Ω(snapshot.Data[1].Group).Should(Equal("deploy"))
+		Ω(snapshot.Data[1].BrokenResource).Should(BeFalse())
+		Ω(snapshot.Data[1].BrokenResources).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("state durations", func() {
+	var config *summary.Config
+
+	BeforeEach(func() {
+		setupMultiple([]MockRoute{
+			{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/jobs", transitionJobsPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
+		})
+		config = &summary.Config{
+			Protocol:  "http",
+			Templates: template.Must(template.ParseGlob("../templates/*")),
+		}
+	})
+
+	AfterEach(func() {
+		teardown()
+	})
+
+	It("dates each pipeline group's state from the transition builds of its jobs", func() {
+		config, err := summary.SetupConfig("", "", "", "", "", "", "")
+		Ω(err).Should(BeNil())
+		config.Protocol = "http"
+		config.Team = ""
+
+		snapshot := config.Cache.Get(Host(server))
+		Ω(snapshot.Err).Should(BeNil())
+		Ω(snapshot.Data).Should(HaveLen(2))
+
+		// green from when the last job turned green
+		Ω(snapshot.Data[0].Group).Should(Equal("build"))
+		Ω(snapshot.Data[0].Since).Should(Equal(time.Unix(1504793700, 0)))
+		Ω(snapshot.Data[0].LastGreen).Should(BeTemporally("~", time.Now(), time.Minute))
+		Ω(snapshot.Data[0].RedFor()).Should(BeEmpty())
+
+		// failed from when the first failed job failed, errored jobs don't count
+		Ω(snapshot.Data[1].Group).Should(Equal("deploy"))
+		Ω(snapshot.Data[1].Since).Should(Equal(time.Unix(1504800100, 0)))
+		Ω(snapshot.Data[1].LastGreen.IsZero()).Should(BeTrue())
+		Ω(snapshot.Data[1].RedFor()).Should(MatchRegexp(`^\d+d \d+h$`))
+	})
+
+	It("shows how long failing tiles have been red", func() {
+		mockRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://example.com/host/%s", Host(server)), nil)
+		Router(config).ServeHTTP(mockRecorder, req)
+
+		Ω(mockRecorder.Code).Should(Equal(200))
+		body := stringMinifier(mockRecorder.Body.String())
+		Ω(body).Should(MatchRegexp(`<spanclass="deploy"><span>deploy</span></span><spanclass="red_for"><span>redfor\d+d\d+h</span></span>`))
+		Ω(strings.Count(body, `class="red_for"`)).Should(Equal(1))
+	})
+
+	It("serves when each pipeline group entered its state and was last green", func() {
+		_, body := apiGet(config, fmt.Sprintf("/api/v1/host/%s", Host(server)))
+
+		pipelines := body["pipelines"].([]interface{})
+		Ω(pipelines).Should(HaveLen(2))
+		Ω(pipelines[0].(map[string]interface{})["since"]).Should(Equal("2017-09-07T14:15:00Z"))
+		Ω(pipelines[0].(map[string]interface{})["last_green"]).ShouldNot(BeNil())
+		Ω(pipelines[1].(map[string]interface{})["since"]).Should(Equal("2017-09-07T16:01:40Z"))
+		Ω(pipelines[1].(map[string]interface{})["last_green"]).Should(BeNil())
+	})
+})
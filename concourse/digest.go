@@ -0,0 +1,307 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	defaultDigestAt       = "09:00"
+	defaultDigestTimezone = "UTC"
+	defaultSMTPPort       = 25
+)
+
+var digestDays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// digestStates are the states of pipeline groups which are listed in a digest
+var digestStates = []string{"failed", "errored", "broken", "paused"}
+
+// Digest emails a summary of the pipelines needing attention in each concourse
+// summary group, once a day at a set time
+type Digest struct {
+	SMTP     SMTP     `json:"smtp"`
+	To       []string `json:"to"`
+	Subject  string   `json:"subject"`
+	At       string   `json:"at"`
+	Timezone string   `json:"timezone"`
+	Days     []string `json:"days"`
+	Groups   []string `json:"groups"`
+	Config   *Config  `json:"-"`
+
+	hour      int
+	minute    int
+	location  *time.Location
+	days      map[time.Weekday]bool
+	mutex     sync.Mutex
+	stop      chan struct{}
+	waitGroup sync.WaitGroup
+}
+
+// SMTP is the mail server a digest is sent through, authentication is only used
+// when a username is given
+type SMTP struct {
+	Host     string `json:"host"`
+	Port     int    `json:"port"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	From     string `json:"from"`
+}
+
+// digestEntry is a pipeline group listed in a digest, since is zero when it isn't
+// known how long the pipeline group has been in its state
+type digestEntry struct {
+	Name  string
+	State string
+	URL   string
+	Since time.Time
+}
+
+// SetupDigest parses the email digest configuration, nil is returned when it isn't
+// configured
+func SetupDigest(digestJSON string) (*Digest, error) {
+	if digestJSON == "" {
+		return nil, nil
+	}
+
+	var digest Digest
+	if err := json.Unmarshal([]byte(digestJSON), &digest); err != nil {
+		return nil, err
+	}
+
+	if digest.SMTP.Host == "" {
+		return nil, errors.New("digest smtp host is required")
+	}
+	if digest.SMTP.From == "" {
+		return nil, errors.New("digest smtp from is required")
+	}
+	if digest.SMTP.Port == 0 {
+		digest.SMTP.Port = defaultSMTPPort
+	}
+	if len(digest.To) == 0 {
+		return nil, errors.New("digest needs at least one to address")
+	}
+
+	if digest.At == "" {
+		digest.At = defaultDigestAt
+	}
+	at, err := time.Parse("15:04", digest.At)
+	if err != nil {
+		return nil, fmt.Errorf("digest at %q must be a time such as 09:00", digest.At)
+	}
+	digest.hour, digest.minute = at.Hour(), at.Minute()
+
+	if digest.Timezone == "" {
+		digest.Timezone = defaultDigestTimezone
+	}
+	if digest.location, err = time.LoadLocation(digest.Timezone); err != nil {
+		return nil, fmt.Errorf("digest timezone: %s", err.Error())
+	}
+
+	digest.days = map[time.Weekday]bool{}
+	for _, day := range digest.Days {
+		weekday, ok := digestDays[strings.ToLower(day)]
+		if !ok {
+			return nil, fmt.Errorf("digest day %q must be one of mon, tue, wed, thu, fri, sat or sun", day)
+		}
+		digest.days[weekday] = true
+	}
+	return &digest, nil
+}
+
+// Next returns the first time the digest is due after now, in the digest's timezone
+func (d *Digest) Next(now time.Time) time.Time {
+	local := now.In(d.location)
+	for i := 0; i <= 7; i++ {
+		next := time.Date(local.Year(), local.Month(), local.Day()+i, d.hour, d.minute, 0, 0, d.location)
+		if next.After(now) && (len(d.days) == 0 || d.days[next.Weekday()]) {
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+// Start sends the digest whenever it is due until stopped
+func (d *Digest) Start() {
+	d.stop = make(chan struct{})
+	d.waitGroup.Add(1)
+	go func() {
+		defer d.waitGroup.Done()
+
+		for {
+			timer := time.NewTimer(time.Until(d.Next(time.Now())))
+			select {
+			case <-d.stop:
+				timer.Stop()
+				return
+			case <-timer.C:
+				if err := d.Send(); err != nil {
+					fmt.Println(err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// Stop halts the schedule and waits for a digest being sent
+func (d *Digest) Stop() {
+	close(d.stop)
+	d.waitGroup.Wait()
+}
+
+// duration formats how long something has lasted to the two most significant units
+func duration(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%dd %dh", d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh %dm", d/time.Hour, d%time.Hour/time.Minute)
+	default:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+}
+
+// Reload sends the digest from the groups of config from then on
+func (d *Digest) Reload(config *Config) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.Config = config
+}
+
+func (d *Digest) config() *Config {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.Config
+}
+
+func (d *Digest) csGroups() CSGroups {
+	config := d.config()
+	if len(d.Groups) == 0 {
+		return config.CSGroups
+	}
+	var csGroups CSGroups
+	for _, csGroup := range config.CSGroups {
+		if contains(d.Groups, csGroup.Group) {
+			csGroups = append(csGroups, csGroup)
+		}
+	}
+	return csGroups
+}
+
+// entries returns the pipeline groups of a concourse summary group which need
+// attention, most severe first, and the errors of hosts which couldn't be fetched
+func (d *Digest) entries(csGroup CSGroup) ([]digestEntry, []string) {
+	var (
+		entries    []digestEntry
+		hostErrors []string
+	)
+	for _, groupData := range d.config().groupData(csGroup) {
+		if groupData.Error != "" {
+			hostErrors = append(hostErrors, fmt.Sprintf("%s could not be fetched: %s", groupData.Host, groupData.Error))
+			continue
+		}
+		for _, datum := range groupData.Statuses {
+			state := transitionState(datum)
+			if !contains(digestStates, state) {
+				continue
+			}
+			entries = append(entries, digestEntry{
+				Name:  ccName(datum.Host, datum.Team, datum.Pipeline, datum.Group),
+				State: state,
+				URL:   datum.URL,
+				Since: datum.Since,
+			})
+		}
+	}
+
+	severity := map[string]int{}
+	for i, state := range digestStates {
+		severity[state] = i
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return severity[entries[i].State] < severity[entries[j].State]
+	})
+	return entries, hostErrors
+}
+
+// body renders the plain text of the digest and returns the number of pipeline
+// groups listed
+func (d *Digest) body(now time.Time) (string, int) {
+	var (
+		buffer bytes.Buffer
+		total  int
+	)
+	for _, csGroup := range d.csGroups() {
+		entries, hostErrors := d.entries(csGroup)
+		total += len(entries)
+
+		fmt.Fprintf(&buffer, "%s\n", csGroup.Group)
+		if len(entries) == 0 && len(hostErrors) == 0 {
+			fmt.Fprint(&buffer, "  every pipeline is green\n")
+		}
+		for _, entry := range entries {
+			since := ""
+			if !entry.Since.IsZero() {
+				since = fmt.Sprintf(" for %s", duration(now.Sub(entry.Since)))
+			}
+			fmt.Fprintf(&buffer, "  %s: %s%s\n    %s\n", entry.Name, entry.State, since, entry.URL)
+		}
+		for _, hostError := range hostErrors {
+			fmt.Fprintf(&buffer, "  %s\n", hostError)
+		}
+		fmt.Fprint(&buffer, "\n")
+	}
+	return buffer.String(), total
+}
+
+// message builds the email of the digest
+func (d *Digest) message(now time.Time) []byte {
+	body, total := d.body(now)
+
+	subject := d.Subject
+	if subject == "" {
+		subject = fmt.Sprintf("Concourse summary: %d pipelines need attention", total)
+	}
+
+	var message bytes.Buffer
+	fmt.Fprintf(&message, "From: %s\r\n", d.SMTP.From)
+	fmt.Fprintf(&message, "To: %s\r\n", strings.Join(d.To, ", "))
+	fmt.Fprintf(&message, "Subject: %s\r\n", subject)
+	fmt.Fprintf(&message, "Date: %s\r\n", now.In(d.location).Format(time.RFC1123Z))
+	fmt.Fprint(&message, "MIME-Version: 1.0\r\n")
+	fmt.Fprint(&message, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprint(&message, strings.Replace(body, "\n", "\r\n", -1))
+	return message.Bytes()
+}
+
+// Send emails the digest now
+func (d *Digest) Send() error {
+	var auth smtp.Auth
+	if d.SMTP.Username != "" {
+		auth = smtp.PlainAuth("", d.SMTP.Username, d.SMTP.Password, d.SMTP.Host)
+	}
+
+	address := net.JoinHostPort(d.SMTP.Host, strconv.Itoa(d.SMTP.Port))
+	if err := smtp.SendMail(address, auth, d.SMTP.From, d.To, d.message(time.Now())); err != nil {
+		return fmt.Errorf("digest: %s", err.Error())
+	}
+	return nil
+}
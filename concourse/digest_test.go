@@ -0,0 +1,284 @@
+package summary_test
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+type sentMail struct {
+	Auth string
+	From string
+	To   []string
+	Data string
+}
+
+// fakeSMTP is a local stand in for a mail server, it accepts every message and
+// records it
+type fakeSMTP struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	mails    []sentMail
+}
+
+func newFakeSMTP() *fakeSMTP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	Expect(err).ToNot(HaveOccurred())
+
+	f := &fakeSMTP{listener: listener}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeSMTP) Port() int {
+	return f.listener.Addr().(*net.TCPAddr).Port
+}
+
+func (f *fakeSMTP) Close() {
+	f.listener.Close()
+}
+
+func (f *fakeSMTP) Mails() []sentMail {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.mails
+}
+
+func (f *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	reply := func(line string) {
+		conn.Write([]byte(line + "\r\n"))
+	}
+
+	var mail sentMail
+	reply("220 localhost fake smtp")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+		switch command {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			mail.Auth = line
+			reply("235 authenticated")
+		case "MAIL":
+			mail.From = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			mail.To = append(mail.To, strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 go ahead")
+			var data []string
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if line == ".\r\n" {
+					break
+				}
+				data = append(data, line)
+			}
+			mail.Data = strings.Join(data, "")
+			f.mutex.Lock()
+			f.mails = append(f.mails, mail)
+			f.mutex.Unlock()
+			mail = sentMail{}
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+var _ = Describe("Digest", func() {
+	Describe("SetupDigest", func() {
+		It("returns nil when the digest isn't configured", func() {
+			digest, err := summary.SetupDigest("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest).To(BeNil())
+		})
+
+		It("defaults the port, time and timezone", func() {
+			digest, err := summary.SetupDigest(`{"smtp": {"host": "mail.example.com", "from": "ci@example.com"}, "to": ["team@example.com"]}`)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(digest.SMTP.Port).To(Equal(25))
+			Expect(digest.At).To(Equal("09:00"))
+			Expect(digest.Timezone).To(Equal("UTC"))
+		})
+
+		It("requires an smtp host, from and to addresses", func() {
+			_, err := summary.SetupDigest(`{"smtp": {"from": "ci@example.com"}, "to": ["team@example.com"]}`)
+			Expect(err).To(MatchError("digest smtp host is required"))
+
+			_, err = summary.SetupDigest(`{"smtp": {"host": "mail.example.com"}, "to": ["team@example.com"]}`)
+			Expect(err).To(MatchError("digest smtp from is required"))
+
+			_, err = summary.SetupDigest(`{"smtp": {"host": "mail.example.com", "from": "ci@example.com"}}`)
+			Expect(err).To(MatchError("digest needs at least one to address"))
+		})
+
+		It("rejects invalid times, timezones and days", func() {
+			_, err := summary.SetupDigest(`{"smtp": {"host": "mail.example.com", "from": "ci@example.com"}, "to": ["a@example.com"], "at": "9am"}`)
+			Expect(err).To(MatchError(`digest at "9am" must be a time such as 09:00`))
+
+			_, err = summary.SetupDigest(`{"smtp": {"host": "mail.example.com", "from": "ci@example.com"}, "to": ["a@example.com"], "timezone": "Mars/Olympus"}`)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("digest timezone:"))
+
+			_, err = summary.SetupDigest(`{"smtp": {"host": "mail.example.com", "from": "ci@example.com"}, "to": ["a@example.com"], "days": ["someday"]}`)
+			Expect(err).To(MatchError(`digest day "someday" must be one of mon, tue, wed, thu, fri, sat or sun`))
+		})
+	})
+
+	Describe("Next", func() {
+		var digest *summary.Digest
+
+		BeforeEach(func() {
+			var err error
+			digest, err = summary.SetupDigest(`{"smtp": {"host": "mail.example.com", "from": "ci@example.com"}, "to": ["a@example.com"], "at": "09:00", "timezone": "America/New_York"}`)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("is due later the same day in the digest's timezone", func() {
+			now := time.Date(2017, 9, 7, 12, 0, 0, 0, time.UTC)
+			Expect(digest.Next(now).UTC()).To(Equal(time.Date(2017, 9, 7, 13, 0, 0, 0, time.UTC)))
+		})
+
+		It("is due the next day once the time has passed", func() {
+			now := time.Date(2017, 9, 7, 13, 0, 0, 0, time.UTC)
+			Expect(digest.Next(now).UTC()).To(Equal(time.Date(2017, 9, 8, 13, 0, 0, 0, time.UTC)))
+		})
+
+		It("is only due on the configured days", func() {
+			var err error
+			digest, err = summary.SetupDigest(`{"smtp": {"host": "mail.example.com", "from": "ci@example.com"}, "to": ["a@example.com"], "days": ["Mon"]}`)
+			Expect(err).ToNot(HaveOccurred())
+
+			// a thursday
+			now := time.Date(2017, 9, 7, 12, 0, 0, 0, time.UTC)
+			Expect(digest.Next(now)).To(Equal(time.Date(2017, 9, 11, 9, 0, 0, 0, digest.Next(now).Location())))
+		})
+	})
+
+	Describe("Send", func() {
+		var (
+			smtpServer *fakeSMTP
+			digest     *summary.Digest
+		)
+
+		BeforeEach(func() {
+			smtpServer = newFakeSMTP()
+
+			failing := pipelineData("deploy", map[string]int{"failed": 1, "succeeded": 1})
+			failing.Since = time.Now().Add(-3*time.Hour - 5*time.Minute)
+			paused := pipelineData("release", map[string]int{"succeeded": 1})
+			paused.Paused = true
+			green := pipelineData("build", map[string]int{"succeeded": 3})
+
+			fetch := func(host string) ([]summary.Data, error) {
+				if host == "other.example.com" {
+					return nil, errors.New("dial tcp: i/o timeout")
+				}
+				return []summary.Data{green, failing, paused}, nil
+			}
+
+			var err error
+			digest, err = summary.SetupDigest(`{
+				"smtp": {"host": "127.0.0.1", "port": ` + strconv.Itoa(smtpServer.Port()) + `, "from": "ci@example.com"},
+				"to": ["team@example.com", "lead@example.com"]
+			}`)
+			Expect(err).ToNot(HaveOccurred())
+			digest.Config = &summary.Config{
+				Cache: summary.NewCache(time.Minute, fetch),
+				CSGroups: summary.CSGroups{
+					{Group: "deployers", Hosts: []summary.Host{{FQDN: "ci.example.com", Pipelines: []summary.Pipeline{{Name: "deploy"}, {Name: "release"}}}}},
+					{Group: "builders", Hosts: []summary.Host{{FQDN: "ci.example.com", Pipelines: []summary.Pipeline{{Name: "build"}}}}},
+					{Group: "elsewhere", Hosts: []summary.Host{{FQDN: "other.example.com"}}},
+				},
+			}
+		})
+
+		AfterEach(func() {
+			smtpServer.Close()
+		})
+
+		It("emails every red pipeline per group with how long it has been in its state", func() {
+			Expect(digest.Send()).To(Succeed())
+
+			mails := smtpServer.Mails()
+			Expect(mails).To(HaveLen(1))
+			Expect(mails[0].From).To(Equal("ci@example.com"))
+			Expect(mails[0].To).To(Equal([]string{"team@example.com", "lead@example.com"}))
+			Expect(mails[0].Auth).To(BeEmpty())
+
+			data := mails[0].Data
+			Expect(data).To(ContainSubstring("Subject: Concourse summary: 2 pipelines need attention\r\n"))
+			Expect(data).To(ContainSubstring("Content-Type: text/plain; charset=UTF-8\r\n"))
+			Expect(data).To(ContainSubstring("deployers\r\n" +
+				"  ci.example.com/main/deploy: failed for 3h 5m\r\n" +
+				"    https://ci.example.com/teams/main/pipelines/deploy\r\n" +
+				"  ci.example.com/main/release: paused\r\n" +
+				"    https://ci.example.com/teams/main/pipelines/release\r\n"))
+			Expect(data).To(ContainSubstring("builders\r\n  every pipeline is green\r\n"))
+			Expect(data).To(ContainSubstring("elsewhere\r\n  other.example.com could not be fetched: error\r\n"))
+		})
+
+		It("only includes the configured groups", func() {
+			digest.Groups = []string{"builders"}
+			Expect(digest.Send()).To(Succeed())
+
+			mails := smtpServer.Mails()
+			Expect(mails).To(HaveLen(1))
+			Expect(mails[0].Data).To(ContainSubstring("Subject: Concourse summary: 0 pipelines need attention\r\n"))
+			Expect(mails[0].Data).ToNot(ContainSubstring("deployers"))
+		})
+
+		It("authenticates when a username is given", func() {
+			digest.SMTP.Username = "ci"
+			digest.SMTP.Password = "secret"
+			Expect(digest.Send()).To(Succeed())
+
+			mails := smtpServer.Mails()
+			Expect(mails).To(HaveLen(1))
+			Expect(mails[0].Auth).To(HavePrefix("AUTH PLAIN"))
+		})
+
+		It("returns an error when the mail server can't be reached", func() {
+			smtpServer.Close()
+			err := digest.Send()
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(HavePrefix("digest:"))
+		})
+	})
+})
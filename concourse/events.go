@@ -0,0 +1,162 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// eventsHeartbeat is how often a comment is sent to keep idle event streams open
+// through proxies
+var eventsHeartbeat = 30 * time.Second
+
+type tileEvent struct {
+	Key  string `json:"key"`
+	HTML string `json:"html"`
+}
+
+// renderedTiles are the tiles of a summary page rendered to HTML, keyed by tile key
+type renderedTiles map[string]string
+
+func (config *Config) renderTile(name string, data interface{}) string {
+	var buffer bytes.Buffer
+	if err := config.Templates.ExecuteTemplate(&buffer, name, data); err != nil {
+		panic(err.Error())
+	}
+	return buffer.String()
+}
+
+// hostTiles renders the tiles of the host page, a host which can't be fetched has
+// no tiles as the page only shows the error
+func (config *Config) hostTiles(host string) renderedTiles {
+	rendered := renderedTiles{}
+	snapshot := config.hostSnapshot(host)
+	if snapshot.Err != nil {
+		return rendered
+	}
+	for _, tile := range tiles(snapshot.Data) {
+		rendered[tile.Key()] = config.renderTile("tile", tile)
+	}
+	return rendered
+}
+
+// groupTiles renders the tiles of the group page
+func (config *Config) groupTiles(csGroup CSGroup) renderedTiles {
+	rendered := renderedTiles{}
+	for _, groupData := range config.groupData(csGroup) {
+		if groupData.Error != "" {
+			rendered[groupData.Key()] = config.renderTile("hostError", groupData)
+			continue
+		}
+		for _, tile := range groupData.Tiles() {
+			rendered[tile.Key()] = config.renderTile("tile", tile)
+		}
+	}
+	return rendered
+}
+
+// sameTiles reports whether two renders have the same set of tiles, regardless of
+// their content
+func sameTiles(a, b renderedTiles) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// streamTiles sends the tiles of a page as server-sent events whenever one of the
+// hosts it watches is refreshed. Only tiles whose HTML changed are sent, when tiles
+// are added or removed a refresh event asks the page to reload in full. render is
+// called for every update so a reload takes effect on open streams too
+func (config *Config) streamTiles(w http.ResponseWriter, r *http.Request, render func() (watched map[string]bool, tiles renderedTiles)) {
+	flusher, ok := w.(http.Flusher)
+	if !ok || config.Cache == nil {
+		w.WriteHeader(http.StatusNotImplemented)
+		fmt.Fprint(w, "Streaming updates are not supported")
+		return
+	}
+
+	updates, unsubscribe := config.Cache.Subscribe()
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	send := func(event string, data interface{}) {
+		body, _ := json.Marshal(data)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, body)
+	}
+
+	// the page may have been rendered before the latest refresh so every tile is
+	// sent when the stream opens
+	watched, sent := render()
+	for key, html := range sent {
+		send("tile", tileEvent{Key: key, HTML: html})
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(eventsHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		case snapshot := <-updates:
+			if !watched[snapshot.Host] {
+				continue
+			}
+
+			var rendered renderedTiles
+			watched, rendered = render()
+			if !sameTiles(sent, rendered) {
+				send("refresh", struct{}{})
+			} else {
+				for key, html := range rendered {
+					if sent[key] != html {
+						send("tile", tileEvent{Key: key, HTML: html})
+					}
+				}
+			}
+			sent = rendered
+		}
+		flusher.Flush()
+	}
+}
+
+// HostEvents streams changes to the tiles of the host page
+func (s *Server) HostEvents(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+	s.current().streamTiles(w, r, func() (map[string]bool, renderedTiles) {
+		return map[string]bool{host: true}, s.current().hostTiles(host)
+	})
+}
+
+// GroupEvents streams changes to the tiles of the group page, following the group's
+// hosts as they change
+func (s *Server) GroupEvents(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+	s.current().streamTiles(w, r, func() (map[string]bool, renderedTiles) {
+		config := s.current()
+		csGroup := config.CSGroups.group(group)
+
+		watched := map[string]bool{}
+		for _, host := range config.groupHosts(csGroup) {
+			watched[host.FQDN] = true
+		}
+		return watched, config.groupTiles(csGroup)
+	})
+}
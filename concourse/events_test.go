@@ -0,0 +1,189 @@
+package summary_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+type serverEvent struct {
+	Name string
+	Key  string `json:"key"`
+	HTML string `json:"html"`
+}
+
+func readEvents(body *bufio.Reader, events chan<- serverEvent) {
+	defer GinkgoRecover()
+	defer close(events)
+
+	var event serverEvent
+	for {
+		line, err := body.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSuffix(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event.Name = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			Ω(json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &event)).Should(Succeed())
+		case line == "" && event.Name != "":
+			events <- event
+			event = serverEvent{}
+		}
+	}
+}
+
+var _ = Describe("Server-sent events", func() {
+	var (
+		templates     = template.Must(template.ParseGlob("../templates/*"))
+		fetcher       *fakeFetcher
+		config        *summary.Config
+		summaryServer *httptest.Server
+		response      *http.Response
+		events        chan serverEvent
+		path          string
+	)
+
+	setData := func(data []summary.Data) {
+		fetcher.mutex.Lock()
+		defer fetcher.mutex.Unlock()
+
+		fetcher.data = data
+	}
+
+	BeforeEach(func() {
+		fetcher = &fakeFetcher{
+			data: []summary.Data{
+				{Host: "ci.example.com", Pipeline: "deploy", Statuses: map[string]int{"succeeded": 1}},
+			},
+		}
+		config = &summary.Config{
+			Templates: templates,
+			Cache:     summary.NewCache(time.Minute, fetcher.fetch),
+			CSGroups: []summary.CSGroup{
+				{Group: "test", Hosts: []summary.Host{{FQDN: "ci.example.com"}}},
+			},
+		}
+		config.Cache.Refresh("ci.example.com")
+		summaryServer = httptest.NewServer(Router(config))
+		path = "/host/ci.example.com/events"
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		response, err = http.Get(summaryServer.URL + path)
+		Ω(err).Should(BeNil())
+
+		events = make(chan serverEvent, 10)
+		go readEvents(bufio.NewReader(response.Body), events)
+	})
+
+	AfterEach(func() {
+		response.Body.Close()
+		summaryServer.Close()
+	})
+
+	It("streams every tile when it opens", func() {
+		Ω(response.Header.Get("Content-Type")).Should(Equal("text/event-stream"))
+
+		var event serverEvent
+		Eventually(events).Should(Receive(&event))
+		Ω(event.Name).Should(Equal("tile"))
+		Ω(event.Key).Should(Equal("ci.example.com::deploy:"))
+		Ω(event.HTML).Should(ContainSubstring(`<div class="succeeded" style="width: 100%;"></div>`))
+	})
+
+	It("streams only tiles which change", func() {
+		Eventually(events).Should(Receive())
+
+		config.Cache.Refresh("ci.example.com")
+		config.Cache.Refresh("other.example.com")
+		setData([]summary.Data{
+			{Host: "ci.example.com", Pipeline: "deploy", Statuses: map[string]int{"failed": 1}},
+		})
+		config.Cache.Refresh("ci.example.com")
+
+		var event serverEvent
+		Eventually(events).Should(Receive(&event))
+		Ω(event.Name).Should(Equal("tile"))
+		Ω(event.Key).Should(Equal("ci.example.com::deploy:"))
+		Ω(event.HTML).Should(ContainSubstring(`<div class="failed" style="width: 100%;"></div>`))
+	})
+
+	It("asks the page to reload when tiles are added", func() {
+		Eventually(events).Should(Receive())
+
+		setData([]summary.Data{
+			{Host: "ci.example.com", Pipeline: "deploy", Statuses: map[string]int{"succeeded": 1}},
+			{Host: "ci.example.com", Pipeline: "release", Statuses: map[string]int{"succeeded": 1}},
+		})
+		config.Cache.Refresh("ci.example.com")
+
+		var event serverEvent
+		Eventually(events).Should(Receive(&event))
+		Ω(event.Name).Should(Equal("refresh"))
+	})
+
+	Context("when the config is reloaded", func() {
+		var server *summary.Server
+
+		BeforeEach(func() {
+			summaryServer.Close()
+			server = summary.CreateServer(config)
+			summaryServer = httptest.NewServer(server.Start())
+			path = "/group/test/events"
+		})
+
+		It("renders open streams with the new config", func() {
+			Eventually(events).Should(Receive())
+
+			server.Reload(&summary.Config{
+				CSGroups: []summary.CSGroup{
+					{Group: "test", Hosts: []summary.Host{{FQDN: "ci.example.com", Pipelines: []summary.Pipeline{{Name: "release"}}}}},
+				},
+			})
+			setData([]summary.Data{
+				{Host: "ci.example.com", Pipeline: "deploy", Statuses: map[string]int{"succeeded": 1}},
+				{Host: "ci.example.com", Pipeline: "release", Statuses: map[string]int{"failed": 1}},
+			})
+			config.Cache.Refresh("ci.example.com")
+
+			// the group now shows release rather than deploy
+			var event serverEvent
+			Eventually(events).Should(Receive(&event))
+			Ω(event.Name).Should(Equal("refresh"))
+			Consistently(events).ShouldNot(BeClosed())
+		})
+	})
+
+	Context("for a group", func() {
+		BeforeEach(func() {
+			path = "/group/test/events"
+		})
+
+		It("streams the tiles of every host in the group", func() {
+			var event serverEvent
+			Eventually(events).Should(Receive(&event))
+			Ω(event.Key).Should(Equal("ci.example.com::deploy:"))
+
+			setData([]summary.Data{
+				{Host: "ci.example.com", Pipeline: "deploy", Running: true, Statuses: map[string]int{"succeeded": 1}},
+			})
+			config.Cache.Refresh("ci.example.com")
+
+			Eventually(events).Should(Receive(&event))
+			Ω(event.HTML).Should(ContainSubstring(`class="outer running"`))
+		})
+	})
+})
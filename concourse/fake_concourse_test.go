@@ -74,13 +74,13 @@ func setupMultiple(mockEndpoints []MockRoute) {
 				testQueryString(r.URL.RawQuery, queryString)
 				testPostQuery(r, postFormBody)
 				w.WriteHeader(status)
-				fmt.Fprintf(w, output)
+				fmt.Fprint(w, output)
 			}).Methods(method)
 		} else {
 			router.HandleFunc(endpoint, func(w http.ResponseWriter, r *http.Request) {
 				testQueryString(r.URL.RawQuery, queryString)
 				w.WriteHeader(status)
-				fmt.Fprintf(w, output)
+				fmt.Fprint(w, output)
 			}).Methods(method)
 		}
 	}
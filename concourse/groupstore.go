@@ -0,0 +1,327 @@
+package summary
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	groupStoreBucket   = []byte("groups")
+	maxStoredGroupSize = int64(1 << 20)
+)
+
+// GroupStore keeps the concourse summary groups managed through the groups API in an
+// embedded database, so teams can build their own groups without a redeploy. Stored
+// groups are shown after the configured groups, every request to the groups API
+// must bear the store's token
+type GroupStore struct {
+	Path      string `json:"path"`
+	Token     string `json:"token"`
+	TokenFile string `json:"token_file"`
+
+	db *bolt.DB
+}
+
+type apiGroupConfigs struct {
+	Version string           `json:"version"`
+	Groups  []apiGroupConfig `json:"groups"`
+}
+
+type apiGroupConfigResponse struct {
+	Version string `json:"version"`
+	apiGroupConfig
+}
+
+type apiGroupConfig struct {
+	CSGroup
+	Stored bool `json:"stored"`
+}
+
+// SetupGroupStore parses the group store configuration and opens its database, nil
+// is returned when it isn't configured
+func SetupGroupStore(groupStoreJSON string) (*GroupStore, error) {
+	store, err := ParseGroupStore(groupStoreJSON)
+	if store == nil || err != nil {
+		return nil, err
+	}
+	if err := store.Open(); err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
+// ParseGroupStore parses the group store configuration, reading its token file,
+// without opening its database. nil is returned when it isn't configured
+func ParseGroupStore(groupStoreJSON string) (*GroupStore, error) {
+	if groupStoreJSON == "" {
+		return nil, nil
+	}
+
+	var store GroupStore
+	if err := json.Unmarshal([]byte(groupStoreJSON), &store); err != nil {
+		return nil, err
+	}
+
+	if store.Path == "" {
+		return nil, errors.New("group store path is required")
+	}
+	if store.TokenFile != "" {
+		token, err := ioutil.ReadFile(store.TokenFile)
+		if err != nil {
+			return nil, err
+		}
+		store.Token = strings.TrimSpace(string(token))
+	}
+	if store.Token == "" {
+		return nil, errors.New("group store token is required")
+	}
+	return &store, nil
+}
+
+// Open opens the store's database, creating it when it doesn't exist
+func (g *GroupStore) Open() error {
+	db, err := bolt.Open(g.Path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("group store %s: %s", g.Path, err.Error())
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(groupStoreBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	g.db = db
+	return nil
+}
+
+// Groups returns the stored groups in order of their names
+func (g *GroupStore) Groups() (CSGroups, error) {
+	csGroups := CSGroups{}
+	err := g.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(groupStoreBucket).ForEach(func(key, value []byte) error {
+			var csGroup CSGroup
+			if err := json.Unmarshal(value, &csGroup); err != nil {
+				return fmt.Errorf("stored group %s: %s", key, err.Error())
+			}
+			csGroups = append(csGroups, csGroup)
+			return nil
+		})
+	})
+	return csGroups, err
+}
+
+// Put stores a group, replacing the stored group of the same name, and reports
+// whether the group is new
+func (g *GroupStore) Put(csGroup CSGroup) (bool, error) {
+	value, err := json.Marshal(csGroup)
+	if err != nil {
+		return false, err
+	}
+
+	var created bool
+	err = g.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(groupStoreBucket)
+		created = bucket.Get([]byte(csGroup.Group)) == nil
+		return bucket.Put([]byte(csGroup.Group), value)
+	})
+	return created, err
+}
+
+// Delete removes a stored group and reports whether there was one
+func (g *GroupStore) Delete(group string) (bool, error) {
+	var deleted bool
+	err := g.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(groupStoreBucket)
+		deleted = bucket.Get([]byte(group)) != nil
+		return bucket.Delete([]byte(group))
+	})
+	return deleted, err
+}
+
+// Close closes the store's database
+func (g *GroupStore) Close() error {
+	return g.db.Close()
+}
+
+// merge returns the configured groups followed by the stored groups, and how many
+// stored groups there are. A stored group is hidden by a configured group of the
+// same name, which can happen when the config is reloaded
+func (g *GroupStore) merge(configured CSGroups) (CSGroups, int) {
+	merged := append(CSGroups{}, configured...)
+
+	stored, err := g.Groups()
+	if err != nil {
+		fmt.Println(err.Error())
+		return merged, 0
+	}
+	for _, csGroup := range stored {
+		if configured.group(csGroup.Group).Group != "" {
+			fmt.Printf("stored group %s is hidden by the configured group of the same name\n", csGroup.Group)
+			continue
+		}
+		merged = append(merged, csGroup)
+	}
+	return merged, len(merged) - len(configured)
+}
+
+func (g *GroupStore) authorized(r *http.Request) bool {
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+g.Token)) == 1
+}
+
+// configuredGroups are the groups of the config which aren't stored
+func (config *Config) configuredGroups() CSGroups {
+	return config.CSGroups[:len(config.CSGroups)-config.storedGroups]
+}
+
+// validateStoredGroup checks a group sent to the groups API as a config file's group
+// is checked, paths are given from the root of the request body
+func (config *Config) validateStoredGroup(body []byte, name string) (CSGroup, ConfigErrors) {
+	setting := configSetting{key: "groups", value: string(body)}
+
+	var csGroup CSGroup
+	errs, decoded := decodeSetting(setting, &csGroup)
+	if !decoded {
+		return csGroup, errs
+	}
+
+	if csGroup.Group == "" {
+		csGroup.Group = name
+	} else if csGroup.Group != name {
+		errs = append(errs, setting.error(".group", "must be %q as given in the url, got %q", name, csGroup.Group))
+	}
+	errs = append(errs, validateGroup(setting, "", csGroup)...)
+
+	// as with the config, hosts only need to be configured when hosts are given
+	if len(config.Hosts) > 0 {
+		configured := map[string]bool{}
+		for _, host := range config.Hosts {
+			configured[host.FQDN] = true
+		}
+		for i, host := range csGroup.Hosts {
+			if host.FQDN != "" && !configured[host.FQDN] {
+				errs = append(errs, setting.error(fmt.Sprintf(".hosts[%d].fqdn", i), "host %q is not a configured host", host.FQDN))
+			}
+		}
+	}
+	return csGroup, errs
+}
+
+// authorize only serves requests bearing the group store's token
+func (s *Server) authorize(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.groups.authorized(r) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="concourse-summary"`)
+			writeJSON(w, http.StatusUnauthorized, apiError{Version: apiVersion, Error: "a valid bearer token is required"})
+			return
+		}
+		handler(w, r)
+	}
+}
+
+// APIGroups serves every concourse summary group as JSON, saying which are stored
+func (s *Server) APIGroups(w http.ResponseWriter, r *http.Request) {
+	config := s.current()
+	configured := len(config.configuredGroups())
+
+	groups := []apiGroupConfig{}
+	for i, csGroup := range config.CSGroups {
+		groups = append(groups, apiGroupConfig{CSGroup: csGroup, Stored: i >= configured})
+	}
+	writeJSON(w, http.StatusOK, apiGroupConfigs{Version: apiVersion, Groups: groups})
+}
+
+// APIGroupConfig serves a concourse summary group as JSON
+func (s *Server) APIGroupConfig(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+	config := s.current()
+
+	for i, csGroup := range config.CSGroups {
+		if csGroup.Group == group {
+			stored := i >= len(config.configuredGroups())
+			writeJSON(w, http.StatusOK, apiGroupConfigResponse{Version: apiVersion, apiGroupConfig: apiGroupConfig{CSGroup: csGroup, Stored: stored}})
+			return
+		}
+	}
+	writeJSON(w, http.StatusNotFound, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) not found", group)})
+}
+
+// APIPutGroup creates or replaces a stored group, which is shown straight away
+func (s *Server) APIPutGroup(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+	config := s.current()
+
+	if config.configuredGroups().group(group).Group != "" {
+		writeJSON(w, http.StatusConflict, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) is configured, it can only be changed in the config", group)})
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxStoredGroupSize))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, apiError{Version: apiVersion, Error: err.Error()})
+		return
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		writeJSON(w, http.StatusBadRequest, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) is invalid", group), Problems: []string{"the request body must be the group as JSON"}})
+		return
+	}
+	csGroup, errs := config.validateStoredGroup(body, group)
+	if len(errs) > 0 {
+		problems := make([]string, len(errs))
+		for i, err := range errs {
+			problems[i] = err.Error()
+		}
+		writeJSON(w, http.StatusBadRequest, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) is invalid", group), Problems: problems})
+		return
+	}
+
+	created, err := s.groups.Put(csGroup)
+	if err != nil {
+		fmt.Println(err.Error())
+		writeJSON(w, http.StatusInternalServerError, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) could not be stored", group)})
+		return
+	}
+	s.reloadGroups()
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSON(w, status, apiGroupConfigResponse{Version: apiVersion, apiGroupConfig: apiGroupConfig{CSGroup: csGroup, Stored: true}})
+}
+
+// APIDeleteGroup removes a stored group, which stops being shown straight away
+func (s *Server) APIDeleteGroup(w http.ResponseWriter, r *http.Request) {
+	group := mux.Vars(r)["group"]
+
+	if s.current().configuredGroups().group(group).Group != "" {
+		writeJSON(w, http.StatusConflict, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) is configured, it can only be changed in the config", group)})
+		return
+	}
+
+	deleted, err := s.groups.Delete(group)
+	if err != nil {
+		fmt.Println(err.Error())
+		writeJSON(w, http.StatusInternalServerError, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) could not be deleted", group)})
+		return
+	}
+	if !deleted {
+		writeJSON(w, http.StatusNotFound, apiError{Version: apiVersion, Error: fmt.Sprintf("group (%s) not found", group)})
+		return
+	}
+	s.reloadGroups()
+
+	w.WriteHeader(http.StatusNoContent)
+}
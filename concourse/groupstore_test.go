@@ -0,0 +1,194 @@
+package summary_test
+
+import (
+	"encoding/json"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+var _ = Describe("Group store", func() {
+	var (
+		dir       string
+		storeJSON string
+		store     *summary.GroupStore
+		server    *summary.Server
+		router    http.Handler
+	)
+
+	// request sends a request to the groups API bearing token, returning the recorder
+	// and the decoded body when there is one
+	request := func(method, path, token, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		mockRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest(method, "http://example.com"+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		router.ServeHTTP(mockRecorder, req)
+
+		var decoded map[string]interface{}
+		if mockRecorder.Body.Len() > 0 {
+			Ω(json.Unmarshal(mockRecorder.Body.Bytes(), &decoded)).Should(Succeed())
+		}
+		return mockRecorder, decoded
+	}
+
+	index := func() string {
+		mockRecorder := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/", nil)
+		router.ServeHTTP(mockRecorder, req)
+		return stringMinifier(mockRecorder.Body.String())
+	}
+
+	start := func() {
+		var err error
+		store, err = summary.SetupGroupStore(storeJSON)
+		Ω(err).ShouldNot(HaveOccurred())
+
+		config := &summary.Config{
+			CSGroups:  summary.CSGroups{{Group: "platform", Hosts: []summary.Host{{FQDN: "ci.example.com"}}}},
+			Templates: template.Must(template.ParseGlob("../templates/*")),
+		}
+		server = summary.CreateServer(config)
+		server.StoreGroups(store)
+		router = server.Start()
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "groups")
+		Ω(err).ShouldNot(HaveOccurred())
+
+		storeJSON = `{"path": "` + filepath.Join(dir, "groups.db") + `", "token": "secret"}`
+		start()
+	})
+
+	AfterEach(func() {
+		store.Close()
+		os.RemoveAll(dir)
+	})
+
+	Describe("SetupGroupStore", func() {
+		It("returns nil when it isn't configured", func() {
+			Ω(summary.SetupGroupStore("")).Should(BeNil())
+		})
+
+		It("requires a path and a token", func() {
+			_, err := summary.SetupGroupStore(`{"token": "secret"}`)
+			Ω(err).Should(MatchError("group store path is required"))
+
+			_, err = summary.SetupGroupStore(`{"path": "` + filepath.Join(dir, "other.db") + `"}`)
+			Ω(err).Should(MatchError("group store token is required"))
+		})
+
+		It("reads the token from a file", func() {
+			tokenFile := filepath.Join(dir, "token")
+			Ω(ioutil.WriteFile(tokenFile, []byte("from-file\n"), 0600)).Should(Succeed())
+
+			other, err := summary.SetupGroupStore(`{"path": "` + filepath.Join(dir, "other.db") + `", "token_file": "` + tokenFile + `"}`)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer other.Close()
+			Ω(other.Token).Should(Equal("from-file"))
+		})
+	})
+
+	It("requires the store's token", func() {
+		response, body := request("GET", "/api/v1/groups", "", "")
+		Ω(response.Code).Should(Equal(http.StatusUnauthorized))
+		Ω(response.Header().Get("WWW-Authenticate")).Should(HavePrefix("Bearer"))
+		Ω(body["error"]).Should(Equal("a valid bearer token is required"))
+
+		response, _ = request("PUT", "/api/v1/groups/payments", "wrong", `{"hosts": ["ci.example.com"]}`)
+		Ω(response.Code).Should(Equal(http.StatusUnauthorized))
+	})
+
+	It("creates, replaces and deletes groups, showing them straight away", func() {
+		response, body := request("PUT", "/api/v1/groups/payments", "secret", `{"hosts": [{"fqdn": "ci.example.com", "pipelines": [{"name": "payments-*"}]}]}`)
+		Ω(response.Code).Should(Equal(http.StatusCreated))
+		Ω(body["group"]).Should(Equal("payments"))
+		Ω(body["stored"]).Should(BeTrue())
+		Ω(index()).Should(ContainSubstring(`<ahref="/group/payments">payments</a>`))
+
+		response, _ = request("PUT", "/api/v1/groups/payments", "secret", `{"group": "payments", "select": [{"paused": true}]}`)
+		Ω(response.Code).Should(Equal(http.StatusOK))
+
+		_, body = request("GET", "/api/v1/groups", "secret", "")
+		Ω(body["groups"]).Should(Equal([]interface{}{
+			map[string]interface{}{"group": "platform", "hosts": []interface{}{map[string]interface{}{"fqdn": "ci.example.com", "pipelines": nil}}, "stored": false},
+			map[string]interface{}{"group": "payments", "hosts": nil, "select": []interface{}{map[string]interface{}{"paused": true}}, "stored": true},
+		}))
+
+		response, _ = request("DELETE", "/api/v1/groups/payments", "secret", "")
+		Ω(response.Code).Should(Equal(http.StatusNoContent))
+		Ω(index()).ShouldNot(ContainSubstring("payments"))
+
+		response, body = request("GET", "/api/v1/groups/payments", "secret", "")
+		Ω(response.Code).Should(Equal(http.StatusNotFound))
+		Ω(body["error"]).Should(Equal("group (payments) not found"))
+	})
+
+	It("keeps stored groups across restarts", func() {
+		response, _ := request("PUT", "/api/v1/groups/payments", "secret", `{"select": [{"names": ["payments-*"]}]}`)
+		Ω(response.Code).Should(Equal(http.StatusCreated))
+
+		store.Close()
+		start()
+
+		Ω(index()).Should(ContainSubstring(`<ahref="/group/payments">payments</a>`))
+		response, body := request("GET", "/api/v1/groups/payments", "secret", "")
+		Ω(response.Code).Should(Equal(http.StatusOK))
+		Ω(body["select"]).Should(Equal([]interface{}{map[string]interface{}{"names": []interface{}{"payments-*"}}}))
+	})
+
+	It("keeps stored groups when the config is reloaded", func() {
+		request("PUT", "/api/v1/groups/payments", "secret", `{"select": [{"paused": true}]}`)
+
+		server.Reload(&summary.Config{CSGroups: summary.CSGroups{{Group: "ops"}}})
+		index := index()
+		Ω(index).Should(ContainSubstring(`<ahref="/group/ops">ops</a>`))
+		Ω(index).Should(ContainSubstring(`<ahref="/group/payments">payments</a>`))
+		Ω(index).ShouldNot(ContainSubstring("platform"))
+	})
+
+	It("rejects invalid groups with the problems found", func() {
+		response, body := request("PUT", "/api/v1/groups/payments", "secret", `{"group": "billing", "hosts": [{"pipelines": [{"name": "/(/"}]}], "colour": "red"}`)
+		Ω(response.Code).Should(Equal(http.StatusBadRequest))
+		Ω(body["error"]).Should(Equal("group (payments) is invalid"))
+		Ω(body["problems"]).Should(Equal([]interface{}{
+			".colour: unknown field",
+			`.group: must be "payments" as given in the url, got "billing"`,
+			".hosts[0].fqdn: host fqdn is required",
+			".hosts[0].pipelines[0].name: invalid regular expression /(/: error parsing regexp: missing closing ): `(`",
+		}))
+
+		response, body = request("PUT", "/api/v1/groups/payments", "secret", `{"hosts": [`)
+		Ω(response.Code).Should(Equal(http.StatusBadRequest))
+		Ω(body["problems"]).Should(Equal([]interface{}{"line 1, column 11: unexpected end of JSON input"}))
+
+		response, body = request("PUT", "/api/v1/groups/payments", "secret", "")
+		Ω(response.Code).Should(Equal(http.StatusBadRequest))
+		Ω(body["problems"]).Should(Equal([]interface{}{"the request body must be the group as JSON"}))
+
+		_, body = request("GET", "/api/v1/groups", "secret", "")
+		Ω(body["groups"]).Should(HaveLen(1))
+	})
+
+	It("leaves configured groups to the config", func() {
+		response, body := request("PUT", "/api/v1/groups/platform", "secret", `{"select": [{"paused": true}]}`)
+		Ω(response.Code).Should(Equal(http.StatusConflict))
+		Ω(body["error"]).Should(Equal("group (platform) is configured, it can only be changed in the config"))
+
+		response, _ = request("DELETE", "/api/v1/groups/platform", "secret", "")
+		Ω(response.Code).Should(Equal(http.StatusConflict))
+		Ω(index()).Should(ContainSubstring(`<ahref="/group/platform">platform</a>`))
+	})
+})
@@ -0,0 +1,374 @@
+package summary
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	defaultHistoryRetention       = 30 * 24 * time.Hour
+	defaultHistoryCompactAfter    = 24 * time.Hour
+	defaultHistoryCompactInterval = time.Hour
+
+	historyBucket       = []byte("pipelines")
+	historyStatesBucket = []byte("states")
+)
+
+// History stores the state of every pipeline group each time a host is fetched in
+// an embedded database, so that it survives restarts. Samples older than
+// compact_after are compacted to the first sample of each state and samples older
+// than the retention are removed, apart from the one which started the state
+// current at the time
+type History struct {
+	Path            string `json:"path"`
+	Retention       string `json:"retention"`
+	CompactAfter    string `json:"compact_after"`
+	CompactInterval string `json:"compact_interval"`
+
+	retention       time.Duration
+	compactAfter    time.Duration
+	compactInterval time.Duration
+	db              *bolt.DB
+	stop            chan struct{}
+	waitGroup       sync.WaitGroup
+}
+
+// HistoryRecord is the state of a pipeline group at the time its host was fetched
+type HistoryRecord struct {
+	At       time.Time      `json:"at"`
+	State    string         `json:"state"`
+	Statuses map[string]int `json:"statuses"`
+}
+
+// historyState is the current state of a pipeline group, since when and when it
+// was last green
+type historyState struct {
+	State     string    `json:"state"`
+	Since     time.Time `json:"since"`
+	LastGreen time.Time `json:"last_green"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// SetupHistory parses the history store configuration and opens its database, nil is
+// returned when it isn't configured
+func SetupHistory(historyJSON string) (*History, error) {
+	history, err := ParseHistory(historyJSON)
+	if history == nil || err != nil {
+		return nil, err
+	}
+	if err := history.Open(); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// ParseHistory parses the history store configuration without opening its database,
+// nil is returned when it isn't configured
+func ParseHistory(historyJSON string) (*History, error) {
+	if historyJSON == "" {
+		return nil, nil
+	}
+
+	var history History
+	if err := json.Unmarshal([]byte(historyJSON), &history); err != nil {
+		return nil, err
+	}
+
+	if history.Path == "" {
+		return nil, errors.New("history path is required")
+	}
+
+	durations := []struct {
+		name         string
+		value        string
+		defaultValue time.Duration
+		duration     *time.Duration
+	}{
+		{"retention", history.Retention, defaultHistoryRetention, &history.retention},
+		{"compact_after", history.CompactAfter, defaultHistoryCompactAfter, &history.compactAfter},
+		{"compact_interval", history.CompactInterval, defaultHistoryCompactInterval, &history.compactInterval},
+	}
+	for _, d := range durations {
+		*d.duration = d.defaultValue
+		if d.value == "" {
+			continue
+		}
+		duration, err := time.ParseDuration(d.value)
+		if err != nil || duration <= 0 {
+			return nil, fmt.Errorf("history %s %q must be a positive duration such as 24h", d.name, d.value)
+		}
+		*d.duration = duration
+	}
+	return &history, nil
+}
+
+// Open opens the history's database, creating it when it doesn't exist
+func (h *History) Open() error {
+	db, err := bolt.Open(h.Path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return fmt.Errorf("history %s: %s", h.Path, err.Error())
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{historyBucket, historyStatesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return err
+	}
+
+	h.db = db
+	return nil
+}
+
+// Start compacts the history on an interval until stopped, fetches are recorded
+// by the config the history is set on
+func (h *History) Start() {
+	h.stop = make(chan struct{})
+	h.waitGroup.Add(1)
+	go func() {
+		defer h.waitGroup.Done()
+
+		ticker := time.NewTicker(h.compactInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-h.stop:
+				return
+			case now := <-ticker.C:
+				if err := h.Compact(now); err != nil {
+					fmt.Println(err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// Stop halts compaction and closes the database
+func (h *History) Stop() {
+	if h.stop != nil {
+		close(h.stop)
+		h.waitGroup.Wait()
+	}
+	h.db.Close()
+}
+
+// historyKey orders samples by time, times before the epoch sort first
+func historyKey(at time.Time) []byte {
+	key := make([]byte, 8)
+	if at.After(time.Unix(0, 0)) {
+		binary.BigEndian.PutUint64(key, uint64(at.UnixNano()))
+	}
+	return key
+}
+
+func historyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
+
+// Record stores the state of every pipeline group in a snapshot, snapshots of
+// hosts which couldn't be fetched are skipped
+func (h *History) Record(snapshot Snapshot) error {
+	if snapshot.Err != nil {
+		return nil
+	}
+
+	return h.db.Update(func(tx *bolt.Tx) error {
+		pipelines := tx.Bucket(historyBucket)
+		for _, datum := range snapshot.Data {
+			bucket, err := pipelines.CreateBucketIfNotExists([]byte(datum.Key()))
+			if err != nil {
+				return err
+			}
+			value, err := json.Marshal(HistoryRecord{State: transitionState(datum), Statuses: datum.Statuses})
+			if err != nil {
+				return err
+			}
+			if err := bucket.Put(historyKey(snapshot.FetchedAt), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Annotate sets when each pipeline group entered its state and was last green from
+// the states seen before, so they are known for states which concourse can't date
+// and survive restarts. Times concourse gives are preferred as they are more precise
+// than the refresh interval
+func (h *History) Annotate(data []Data, at time.Time) error {
+	return h.db.Update(func(tx *bolt.Tx) error {
+		states := tx.Bucket(historyStatesBucket)
+		for i := range data {
+			datum := &data[i]
+			key := []byte(datum.Key())
+			state := transitionState(*datum)
+
+			var current historyState
+			previous := states.Get(key)
+			if previous != nil {
+				if err := json.Unmarshal(previous, &current); err != nil {
+					return err
+				}
+			}
+
+			switch {
+			case previous == nil:
+				// the state started before the history did, so only concourse knows
+				current = historyState{State: state, Since: datum.Since}
+			case current.State != state:
+				current.State, current.Since = state, at
+				if !datum.Since.IsZero() && datum.Since.After(current.LastSeen) {
+					current.Since = datum.Since
+				}
+			case current.Since.IsZero():
+				current.Since = datum.Since
+			}
+			if state == "succeeded" {
+				current.LastGreen = at
+			}
+			current.LastSeen = at
+
+			value, err := json.Marshal(current)
+			if err != nil {
+				return err
+			}
+			if err := states.Put(key, value); err != nil {
+				return err
+			}
+
+			datum.Since = current.Since
+			if !current.LastGreen.IsZero() {
+				datum.LastGreen = current.LastGreen
+			}
+		}
+		return nil
+	})
+}
+
+// Records returns the history of a pipeline group, by its key, between two times
+// oldest first
+func (h *History) Records(key string, from, to time.Time) ([]HistoryRecord, error) {
+	var records []HistoryRecord
+	err := h.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyBucket).Bucket([]byte(key))
+		if bucket == nil {
+			return nil
+		}
+
+		cursor := bucket.Cursor()
+		for k, v := cursor.Seek(historyKey(from)); k != nil && !historyTime(k).After(to); k, v = cursor.Next() {
+			var record HistoryRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			record.At = historyTime(k)
+			records = append(records, record)
+		}
+		return nil
+	})
+	return records, err
+}
+
+// Compact removes the samples of each pipeline group which are past retention and
+// repeated states older than compact_after. A pipeline group whose newest sample is
+// past retention, because it has been removed from concourse, is removed entirely
+// along with its state
+func (h *History) Compact(now time.Time) error {
+	retainAfter := now.Add(-h.retention)
+	compactBefore := now.Add(-h.compactAfter)
+
+	return h.db.Update(func(tx *bolt.Tx) error {
+		pipelines := tx.Bucket(historyBucket)
+
+		var removed [][]byte
+		err := pipelines.ForEach(func(name, _ []byte) error {
+			bucket := pipelines.Bucket(name)
+
+			var (
+				keys   [][]byte
+				states []string
+			)
+			err := bucket.ForEach(func(k, v []byte) error {
+				var record HistoryRecord
+				if err := json.Unmarshal(v, &record); err != nil {
+					return err
+				}
+				keys = append(keys, append([]byte{}, k...))
+				states = append(states, record.State)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+
+			if len(keys) == 0 || historyTime(keys[len(keys)-1]).Before(retainAfter) {
+				removed = append(removed, append([]byte{}, name...))
+				return nil
+			}
+
+			// the latest sample before retention is kept as the start of the state
+			// at that time
+			kept := 0
+			for i, k := range keys {
+				if historyTime(k).Before(retainAfter) {
+					kept = i
+				}
+			}
+			expired := append([][]byte{}, keys[:kept]...)
+			for i := kept + 1; i < len(keys); i++ {
+				if historyTime(keys[i]).Before(compactBefore) && states[i] == states[i-1] {
+					expired = append(expired, keys[i])
+				}
+			}
+			for _, k := range expired {
+				if err := bucket.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, name := range removed {
+			if err := pipelines.DeleteBucket(name); err != nil {
+				return err
+			}
+		}
+
+		states := tx.Bucket(historyStatesBucket)
+		var unseen [][]byte
+		err = states.ForEach(func(k, v []byte) error {
+			var state historyState
+			if err := json.Unmarshal(v, &state); err != nil {
+				return err
+			}
+			if state.LastSeen.Before(retainAfter) {
+				unseen = append(unseen, append([]byte{}, k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range unseen {
+			if err := states.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
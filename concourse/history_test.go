@@ -0,0 +1,247 @@
+package summary_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+var _ = Describe("History", func() {
+	var (
+		dir     string
+		history *summary.History
+		deploy  summary.Data
+		start   time.Time
+	)
+
+	setupHistory := func(historyJSON string) {
+		var err error
+		history, err = summary.SetupHistory(historyJSON)
+		Expect(err).ToNot(HaveOccurred())
+	}
+
+	record := func(at time.Time, data ...summary.Data) {
+		Expect(history.Record(summary.Snapshot{Host: "ci.example.com", Data: data, FetchedAt: at})).To(Succeed())
+	}
+
+	states := func(key string) []string {
+		records, err := history.Records(key, time.Time{}, time.Now())
+		Expect(err).ToNot(HaveOccurred())
+
+		var states []string
+		for _, record := range records {
+			states = append(states, record.State)
+		}
+		return states
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "history")
+		Expect(err).ToNot(HaveOccurred())
+
+		deploy = pipelineData("deploy", map[string]int{"succeeded": 2})
+		start = time.Date(2017, 9, 1, 0, 0, 0, 0, time.UTC)
+		setupHistory(`{"path": "` + filepath.Join(dir, "history.db") + `", "retention": "72h", "compact_after": "24h"}`)
+	})
+
+	AfterEach(func() {
+		history.Stop()
+		os.RemoveAll(dir)
+	})
+
+	Describe("SetupHistory", func() {
+		It("returns nil when the history isn't configured", func() {
+			h, err := summary.SetupHistory("")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(h).To(BeNil())
+		})
+
+		It("requires a path", func() {
+			_, err := summary.SetupHistory(`{"retention": "24h"}`)
+			Expect(err).To(MatchError("history path is required"))
+		})
+
+		It("rejects invalid durations", func() {
+			_, err := summary.SetupHistory(`{"path": "` + filepath.Join(dir, "other.db") + `", "retention": "a month"}`)
+			Expect(err).To(MatchError(`history retention "a month" must be a positive duration such as 24h`))
+		})
+	})
+
+	It("records the state of each pipeline group when it was fetched", func() {
+		broken := pipelineData("release", map[string]int{"succeeded": 1})
+		broken.BrokenResource = true
+
+		record(start, deploy, broken)
+		deploy.Statuses = map[string]int{"succeeded": 1, "failed": 1}
+		record(start.Add(time.Minute), deploy, broken)
+
+		records, err := history.Records(deploy.Key(), time.Time{}, time.Now())
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).To(HaveLen(2))
+		Expect(records[0].At.Equal(start)).To(BeTrue())
+		Expect(records[0].State).To(Equal("succeeded"))
+		Expect(records[1].State).To(Equal("failed"))
+		Expect(records[1].Statuses).To(Equal(map[string]int{"succeeded": 1, "failed": 1}))
+
+		Expect(states(broken.Key())).To(Equal([]string{"broken", "broken"}))
+	})
+
+	It("only returns records between the times given", func() {
+		for i := 0; i < 5; i++ {
+			record(start.Add(time.Duration(i)*time.Minute), deploy)
+		}
+
+		records, err := history.Records(deploy.Key(), start.Add(time.Minute), start.Add(3*time.Minute))
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).To(HaveLen(3))
+		Expect(records[0].At.Equal(start.Add(time.Minute))).To(BeTrue())
+	})
+
+	It("skips snapshots of hosts which couldn't be fetched", func() {
+		Expect(history.Record(summary.Snapshot{Host: "ci.example.com", Data: []summary.Data{deploy}, Err: errors.New("timeout")})).To(Succeed())
+		Expect(states(deploy.Key())).To(BeEmpty())
+	})
+
+	It("survives being reopened", func() {
+		record(start, deploy)
+		history.Stop()
+
+		setupHistory(`{"path": "` + filepath.Join(dir, "history.db") + `"}`)
+		Expect(states(deploy.Key())).To(Equal([]string{"succeeded"}))
+	})
+
+	Describe("Compact", func() {
+		It("keeps only the first sample of each state once compact_after has passed", func() {
+			succeeded := deploy
+			failed := pipelineData("deploy", map[string]int{"failed": 1})
+			for i, datum := range []summary.Data{succeeded, succeeded, failed, failed, failed, succeeded} {
+				record(start.Add(time.Duration(i)*time.Hour), datum)
+			}
+			// recent samples are left alone
+			record(start.Add(47*time.Hour), succeeded)
+			record(start.Add(48*time.Hour), succeeded)
+
+			Expect(history.Compact(start.Add(60 * time.Hour))).To(Succeed())
+			Expect(states(deploy.Key())).To(Equal([]string{"succeeded", "failed", "succeeded", "succeeded", "succeeded"}))
+		})
+
+		It("removes samples past retention, apart from the start of the state at the time", func() {
+			failed := pipelineData("deploy", map[string]int{"failed": 1})
+			record(start, deploy)
+			record(start.Add(time.Hour), failed)
+			record(start.Add(100*time.Hour), failed)
+
+			Expect(history.Compact(start.Add(120 * time.Hour))).To(Succeed())
+
+			records, err := history.Records(deploy.Key(), time.Time{}, time.Now())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).To(HaveLen(2))
+			Expect(records[0].At.Equal(start.Add(time.Hour))).To(BeTrue())
+			Expect(records[0].State).To(Equal("failed"))
+		})
+
+		It("removes pipeline groups which haven't been seen within retention", func() {
+			record(start, deploy)
+			record(start.Add(100*time.Hour), pipelineData("release", map[string]int{"succeeded": 1}))
+
+			Expect(history.Compact(start.Add(120 * time.Hour))).To(Succeed())
+			Expect(states(deploy.Key())).To(BeEmpty())
+			Expect(states(pipelineData("release", nil).Key())).To(HaveLen(1))
+		})
+	})
+
+	Describe("Annotate", func() {
+		annotate := func(at time.Time, datum summary.Data) summary.Data {
+			data := []summary.Data{datum}
+			Expect(history.Annotate(data, at)).To(Succeed())
+			return data[0]
+		}
+
+		It("dates a pipeline group first seen from concourse, when it can", func() {
+			deploy.Since = start.Add(-time.Hour)
+			Expect(annotate(start, deploy).Since).To(Equal(start.Add(-time.Hour)))
+
+			release := pipelineData("release", map[string]int{"succeeded": 1})
+			release.Paused = true
+			Expect(annotate(start, release).Since.IsZero()).To(BeTrue())
+		})
+
+		It("dates a change of state from when it was seen and remembers when it was last green", func() {
+			annotate(start, deploy)
+
+			failed := pipelineData("deploy", map[string]int{"failed": 1})
+			annotated := annotate(start.Add(time.Minute), failed)
+			Expect(annotated.Since.Equal(start.Add(time.Minute))).To(BeTrue())
+			Expect(annotated.LastGreen.Equal(start)).To(BeTrue())
+
+			annotated = annotate(start.Add(time.Hour), failed)
+			Expect(annotated.Since.Equal(start.Add(time.Minute))).To(BeTrue())
+			Expect(annotated.LastGreen.Equal(start)).To(BeTrue())
+		})
+
+		It("prefers the time concourse gives for a change of state since it was last seen", func() {
+			annotate(start, deploy)
+
+			failed := pipelineData("deploy", map[string]int{"failed": 1})
+			failed.Since = start.Add(10 * time.Second)
+			Expect(annotate(start.Add(time.Minute), failed).Since.Equal(start.Add(10 * time.Second))).To(BeTrue())
+		})
+
+		It("remembers states across restarts", func() {
+			failed := pipelineData("deploy", map[string]int{"failed": 1})
+			annotate(start, deploy)
+			annotate(start.Add(time.Minute), failed)
+			history.Stop()
+
+			setupHistory(`{"path": "` + filepath.Join(dir, "history.db") + `"}`)
+			annotated := annotate(start.Add(time.Hour), failed)
+			Expect(annotated.Since.Equal(start.Add(time.Minute))).To(BeTrue())
+			Expect(annotated.LastGreen.Equal(start)).To(BeTrue())
+		})
+
+		It("forgets pipeline groups not seen within retention when compacted", func() {
+			failed := pipelineData("deploy", map[string]int{"failed": 1})
+			annotate(start, failed)
+			Expect(history.Compact(start.Add(100 * time.Hour))).To(Succeed())
+
+			Expect(annotate(start.Add(101*time.Hour), failed).Since.IsZero()).To(BeTrue())
+		})
+	})
+
+	Context("when set on a config", func() {
+		BeforeEach(func() {
+			setupMultiple([]MockRoute{
+				{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+				{"GET", "/api/v1/teams/pipelines/test1/jobs", groupedJobsPayload, 200, "", nil},
+				{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
+			})
+		})
+
+		AfterEach(func() {
+			teardown()
+		})
+
+		It("records every fetch of a host", func() {
+			config, err := summary.SetupConfig("", "", "", "", "", "", "")
+			Expect(err).ToNot(HaveOccurred())
+			config.Protocol = "http"
+			config.Team = ""
+			config.History = history
+
+			var snapshot summary.Snapshot
+			for i := 0; i < 20; i++ {
+				snapshot = config.Cache.Refresh(Host(server))
+			}
+			Expect(snapshot.Err).ToNot(HaveOccurred())
+			Expect(states(snapshot.Data[0].Key())).To(HaveLen(20))
+		})
+	})
+})
@@ -0,0 +1,137 @@
+package summary
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// HostTLS is the TLS configuration used to connect to a concourse host
+type HostTLS struct {
+	SkipSSLValidation bool   `json:"skip_ssl_validation"`
+	CACertFile        string `json:"ca_cert_file"`
+}
+
+// UnmarshalJSON allows a host to be given either as its FQDN or as an object
+func (h *Host) UnmarshalJSON(data []byte) error {
+	var fqdn string
+	if err := json.Unmarshal(data, &fqdn); err == nil {
+		*h = Host{FQDN: fqdn}
+		return nil
+	}
+
+	type host Host
+	var object host
+	if err := json.Unmarshal(data, &object); err != nil {
+		return err
+	}
+	*h = Host(object)
+	return nil
+}
+
+// URL is the base URL of the concourse host
+func (h Host) URL() string {
+	if h.Port == 0 {
+		return fmt.Sprintf("%s://%s", h.Protocol, h.FQDN)
+	}
+	return fmt.Sprintf("%s://%s:%d", h.Protocol, h.FQDN, h.Port)
+}
+
+// validate checks the connection settings of a host and reads any credentials held
+// in files
+func (h *Host) validate() error {
+	if h.FQDN == "" {
+		return errors.New("host fqdn is required")
+	}
+
+	switch h.Protocol {
+	case "", "http", "https":
+	default:
+		return fmt.Errorf("host %s: protocol must be http or https, got %q", h.FQDN, h.Protocol)
+	}
+
+	if h.Port < 0 || h.Port > 65535 {
+		return fmt.Errorf("host %s: port %d is out of range", h.FQDN, h.Port)
+	}
+
+	for _, team := range h.Teams {
+		if team == "" {
+			return fmt.Errorf("host %s: team names must not be blank", h.FQDN)
+		}
+	}
+
+	if h.TLS != nil && h.TLS.CACertFile != "" {
+		if _, err := loadCACert(h.TLS.CACertFile); err != nil {
+			return fmt.Errorf("host %s: %s", h.FQDN, err.Error())
+		}
+	}
+
+	if h.Credentials != nil {
+		credentials, err := h.Credentials.resolve()
+		if err != nil {
+			return fmt.Errorf("credentials for %s: %s", h.FQDN, err.Error())
+		}
+		h.Credentials = &credentials
+	}
+	return nil
+}
+
+// host returns the connection settings for a host, settings not configured on the
+// host itself fall back to the config wide defaults
+func (config *Config) host(fqdn string) Host {
+	host := Host{FQDN: fqdn}
+	for _, configured := range config.Hosts {
+		if configured.FQDN == fqdn {
+			host = configured
+			break
+		}
+	}
+	host.Pipelines = nil
+
+	if host.Protocol == "" {
+		host.Protocol = config.Protocol
+	}
+	if host.Team == "" {
+		host.Team = config.Team
+	}
+	if host.TLS == nil {
+		host.TLS = &HostTLS{SkipSSLValidation: config.SkipSSLValidation}
+	}
+	if host.Credentials == nil {
+		if credentials, ok := config.Credentials[fqdn]; ok {
+			host.Credentials = &credentials
+		}
+	}
+	if host.FetchConcurrency == 0 {
+		host.FetchConcurrency = config.FetchConcurrency
+	}
+	return host
+}
+
+func (h HostTLS) config() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: h.SkipSSLValidation}
+	if h.CACertFile != "" {
+		pool, err := loadCACert(h.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func loadCACert(path string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
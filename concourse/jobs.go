@@ -0,0 +1,174 @@
+package summary
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/concourse/atc"
+	"github.com/gorilla/mux"
+)
+
+// JobData is the state of a single concourse job and its latest build
+type JobData struct {
+	Host           string
+	Team           string
+	Pipeline       string
+	Name           string
+	URL            string
+	BuildURL       string
+	Status         string
+	Running        bool
+	Paused         bool
+	LatestBuildNum string
+	StartTime      time.Time
+	EndTime        time.Time
+
+	FinishedBuildNum string
+	FinishedAt       time.Time
+	TransitionAt     time.Time
+}
+
+type jobsStruct struct {
+	Header headerStruct
+	Jobs   []JobData
+}
+
+func newJobData(host Host, team string, pipeline string, job atc.Job) JobData {
+	jobData := JobData{
+		Host:     host.FQDN,
+		Team:     team,
+		Pipeline: pipeline,
+		Name:     job.Name,
+		URL:      fmt.Sprintf("%s%s", host.URL(), job.URL),
+		Status:   "pending",
+		Running:  job.NextBuild != nil,
+		Paused:   job.Paused,
+	}
+	jobData.BuildURL = jobData.URL
+
+	if job.FinishedBuild != nil {
+		jobData.Status = job.FinishedBuild.Status
+		jobData.FinishedBuildNum = job.FinishedBuild.Name
+		jobData.FinishedAt = unixTime(job.FinishedBuild.EndTime)
+	}
+
+	// the transition build is the first build with the job's current status
+	if job.TransitionBuild != nil {
+		jobData.TransitionAt = unixTime(job.TransitionBuild.EndTime)
+	}
+
+	build := job.FinishedBuild
+	if job.NextBuild != nil {
+		build = job.NextBuild
+	}
+	if build != nil {
+		jobData.LatestBuildNum = build.Name
+		jobData.BuildURL = fmt.Sprintf("%s/builds/%s", jobData.URL, build.Name)
+		jobData.StartTime = unixTime(build.StartTime)
+		jobData.EndTime = unixTime(build.EndTime)
+	}
+	return jobData
+}
+
+func unixTime(seconds int64) time.Time {
+	if seconds == 0 {
+		return time.Time{}
+	}
+	return time.Unix(seconds, 0)
+}
+
+// StartTimeAgoDays is the number of whole days since the latest build started
+func (j JobData) StartTimeAgoDays() int {
+	if j.StartTime.IsZero() {
+		return 0
+	}
+	return int(time.Since(j.StartTime).Hours() / 24)
+}
+
+// RunTime is how long the latest build ran for, or has been running for
+func (j JobData) RunTime() string {
+	if j.StartTime.IsZero() {
+		return ""
+	}
+	end := j.EndTime
+	if end.IsZero() {
+		end = time.Now()
+	}
+	return end.Sub(j.StartTime).String()
+}
+
+// JobsURL is the link to the job drilldown page for the pipeline (and group) of d
+func (d Data) JobsURL() string {
+	query := url.Values{}
+	if d.Group != "" {
+		query.Set("group", d.Group)
+	}
+	if d.Team != "" {
+		query.Set("team", d.Team)
+	}
+
+	path := fmt.Sprintf("/host/%s/pipeline/%s", url.PathEscape(d.Host), url.PathEscape(d.Pipeline))
+	if len(query) == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s?%s", path, query.Encode())
+}
+
+// pipelineJobs returns the jobs of a pipeline, optionally limited to a team and a
+// pipeline group, in the order concourse lists them
+func pipelineJobs(data []Data, team, pipeline, group string) ([]JobData, bool) {
+	var (
+		jobs  []JobData
+		found bool
+	)
+	seen := map[string]bool{}
+
+	for _, datum := range data {
+		if datum.Pipeline != pipeline || (team != "" && datum.Team != team) || (group != "" && datum.Group != group) {
+			continue
+		}
+		found = true
+		for _, job := range datum.Jobs {
+			key := fmt.Sprintf("%s:%s", job.Team, job.Name)
+			if !seen[key] {
+				seen[key] = true
+				jobs = append(jobs, job)
+			}
+		}
+	}
+	return jobs, found
+}
+
+// JobsSummary renders and serves the jobs of a single pipeline
+func (config *Config) JobsSummary(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	host := vars["host"]
+	pipeline := vars["pipeline"]
+
+	snapshot := config.hostSnapshot(host)
+	if snapshot.Err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error collecting data from concourse (%s) please refer to logs for more details", host)
+		fmt.Println(snapshot.Err.Error())
+		return
+	}
+
+	jobs, found := pipelineJobs(snapshot.Data, r.URL.Query().Get("team"), pipeline, r.URL.Query().Get("group"))
+	if !found {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprintf(w, "Pipeline (%s) not found on concourse (%s)", pipeline, host)
+		return
+	}
+
+	err := config.Templates.ExecuteTemplate(w, "jobs", jobsStruct{
+		Header: headerStruct{
+			RefreshInterval: config.RefreshInterval,
+		},
+		Jobs: jobs,
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+}
@@ -0,0 +1,117 @@
+package summary_test
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+var _ = Describe("#JobsSummary", func() {
+	var (
+		templates    = template.Must(template.ParseGlob("../templates/*"))
+		mockRecorder *httptest.ResponseRecorder
+		path         string
+	)
+
+	BeforeEach(func() {
+		setupMultiple([]MockRoute{
+			{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/jobs", buildsJobsPayload, 200, "", nil},
+			{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
+		})
+		path = "/pipeline/test1"
+	})
+
+	AfterEach(func() {
+		teardown()
+	})
+
+	JustBeforeEach(func() {
+		mockRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", fmt.Sprintf("http://example.com/host/%s%s", Host(server), path), nil)
+		Router(&summary.Config{Templates: templates, Protocol: "http"}).ServeHTTP(mockRecorder, req)
+	})
+
+	It("renders a tile for every job in the pipeline", func() {
+		Ω(mockRecorder.Code).Should(Equal(200))
+		body := stripHostPort(stringMinifier(mockRecorder.Body.String()))
+		Ω(body).Should(ContainSubstring(stringMinifier(`
+<div class="scalable">
+  <a href="http://127.0.0.1:pppp/teams/main/pipelines/test1/jobs/unit/builds/12" target="_blank" class="outer">
+  <div class="status">
+    <div class="succeeded" style="width: 100%;"></div>
+  </div>`)))
+		Ω(body).Should(ContainSubstring(stringMinifier(`
+  <a href="http://127.0.0.1:pppp/teams/main/pipelines/test1/jobs/deploy/builds/4" target="_blank" class="outer running">
+  <div class="status">
+    <div class="failed" style="width: 100%;"></div>
+  </div>
+  <div class="paused"></div>
+  <div class="inner">
+    <span><span>test1</span></span>
+    <span><span>deploy</span></span>`)))
+		Ω(body).Should(ContainSubstring("1m30s"))
+	})
+
+	Context("when a group is requested", func() {
+		BeforeEach(func() {
+			path = "/pipeline/test1?group=build"
+		})
+
+		It("only renders the jobs in that group", func() {
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring("/jobs/unit/builds/12"))
+			Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring("/jobs/deploy/"))
+		})
+	})
+
+	Context("when the pipeline does not exist", func() {
+		BeforeEach(func() {
+			path = "/pipeline/missing"
+		})
+
+		It("returns not found", func() {
+			Ω(mockRecorder.Code).Should(Equal(404))
+			Ω(mockRecorder.Body.String()).Should(MatchRegexp(`Pipeline \(missing\) not found on concourse \(127.0.0.1:\d{1,6}\)`))
+		})
+	})
+})
+
+var _ = Describe("JobData", func() {
+	It("reports how long ago the latest build started and how long it ran", func() {
+		started := time.Now().Add(-49 * time.Hour)
+		job := summary.JobData{
+			StartTime: started,
+			EndTime:   started.Add(90 * time.Second),
+		}
+		Ω(job.StartTimeAgoDays()).Should(Equal(2))
+		Ω(job.RunTime()).Should(Equal("1m30s"))
+	})
+
+	It("is blank for jobs which have never run", func() {
+		job := summary.JobData{}
+		Ω(job.StartTimeAgoDays()).Should(Equal(0))
+		Ω(job.RunTime()).Should(BeEmpty())
+	})
+})
+
+var _ = Describe("Data#JobsURL", func() {
+	It("links to the drilldown page for the pipeline", func() {
+		Ω(summary.Data{Host: "ci.example.com", Pipeline: "deploy"}.JobsURL()).Should(Equal("/host/ci.example.com/pipeline/deploy"))
+	})
+
+	It("includes the group and team", func() {
+		Ω(summary.Data{Host: "ci.example.com", Team: "main", Pipeline: "deploy", Group: "prod"}.JobsURL()).Should(Equal("/host/ci.example.com/pipeline/deploy?group=prod&team=main"))
+	})
+
+	It("escapes each part of the link", func() {
+		Ω(summary.Data{Host: "ci.example.com", Team: "a&b", Pipeline: "deploy #2?", Group: "x/y"}.JobsURL()).Should(Equal("/host/ci.example.com/pipeline/deploy%20%232%3F?group=x%2Fy&team=a%26b"))
+	})
+})
@@ -0,0 +1,127 @@
+package summary
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// metricStatuses are always exported for every pipeline so that a status with no
+// jobs reads as zero rather than missing
+var metricStatuses = []string{"succeeded", "failed", "errored", "aborted", "paused_job", "pending"}
+
+type metric struct {
+	name   string
+	help   string
+	kind   string
+	values []metricValue
+}
+
+type metricValue struct {
+	labels [][2]string
+	value  float64
+}
+
+func (m *metric) add(value float64, labels ...[2]string) {
+	m.values = append(m.values, metricValue{labels: labels, value: value})
+}
+
+func (m metric) write(buffer *bytes.Buffer) {
+	fmt.Fprintf(buffer, "# HELP %s %s\n", m.name, m.help)
+	fmt.Fprintf(buffer, "# TYPE %s %s\n", m.name, m.kind)
+	for _, value := range m.values {
+		var labels []string
+		for _, label := range value.labels {
+			labels = append(labels, fmt.Sprintf("%s=\"%s\"", label[0], escapeLabel(label[1])))
+		}
+		fmt.Fprintf(buffer, "%s{%s} %v\n", m.name, strings.Join(labels, ","), value.value)
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// pipelineStatuses returns the statuses exported for a pipeline, the fixed set
+// followed by any others concourse reported
+func pipelineStatuses(statuses map[string]int) []string {
+	all := append([]string{}, metricStatuses...)
+	var extra []string
+	for status := range statuses {
+		if !contains(metricStatuses, status) {
+			extra = append(extra, status)
+		}
+	}
+	sort.Strings(extra)
+	return append(all, extra...)
+}
+
+// metrics builds the exported metrics from the snapshots held in the cache, hosts
+// which have never been fetched are not exported
+func (config *Config) metrics() []metric {
+	if config.Cache == nil {
+		return nil
+	}
+
+	jobs := metric{name: "concourse_summary_pipeline_jobs", help: "Number of jobs in a pipeline group by the status of their latest build.", kind: "gauge"}
+	running := metric{name: "concourse_summary_pipeline_running", help: "Whether any job in a pipeline group is running.", kind: "gauge"}
+	paused := metric{name: "concourse_summary_pipeline_paused", help: "Whether a pipeline is paused.", kind: "gauge"}
+	broken := metric{name: "concourse_summary_pipeline_broken_resource", help: "Whether a resource of a pipeline group is failing to check.", kind: "gauge"}
+	up := metric{name: "concourse_summary_host_up", help: "Whether the last fetch from a host succeeded.", kind: "gauge"}
+	duration := metric{name: "concourse_summary_fetch_duration_seconds", help: "Duration of the last fetch from a host.", kind: "gauge"}
+	fetches := metric{name: "concourse_summary_fetches_total", help: "Number of fetches made from a host.", kind: "counter"}
+	errors := metric{name: "concourse_summary_fetch_errors_total", help: "Number of fetches from a host which failed.", kind: "counter"}
+	lastSuccess := metric{name: "concourse_summary_last_success_timestamp_seconds", help: "Unix time of the last successful fetch from a host.", kind: "gauge"}
+
+	for _, snapshot := range config.Cache.Snapshots() {
+		host := [2]string{"host", snapshot.Host}
+
+		up.add(boolValue(snapshot.Err == nil), host)
+		duration.add(snapshot.FetchDuration.Seconds(), host)
+		fetches.add(float64(snapshot.Fetches), host)
+		errors.add(float64(snapshot.Errors), host)
+		if !snapshot.FetchedAt.IsZero() {
+			lastSuccess.add(float64(snapshot.FetchedAt.Unix()), host)
+		}
+
+		for _, datum := range snapshot.Data {
+			labels := [][2]string{
+				host,
+				{"team", datum.Team},
+				{"pipeline", datum.Pipeline},
+				{"group", datum.Group},
+			}
+			for _, status := range pipelineStatuses(datum.Statuses) {
+				jobs.add(float64(datum.Statuses[status]), append(labels, [2]string{"status", status})...)
+			}
+			running.add(boolValue(datum.Running), labels...)
+			paused.add(boolValue(datum.Paused), labels...)
+			broken.add(boolValue(datum.BrokenResource), labels...)
+		}
+	}
+
+	return []metric{jobs, running, paused, broken, up, duration, fetches, errors, lastSuccess}
+}
+
+// Metrics serves the cached data of every host in the prometheus text format,
+// scrapes never query concourse themselves
+func (config *Config) Metrics(w http.ResponseWriter, r *http.Request) {
+	var buffer bytes.Buffer
+	for _, metric := range config.metrics() {
+		metric.write(&buffer)
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	buffer.WriteTo(w)
+}
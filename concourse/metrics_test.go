@@ -0,0 +1,124 @@
+package summary_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+var _ = Describe("#Metrics", func() {
+	var (
+		fetcher      *fakeFetcher
+		config       *summary.Config
+		mockRecorder *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		fetcher = &fakeFetcher{
+			data: []summary.Data{
+				{
+					Host:           "ci.example.com",
+					Team:           "main",
+					Pipeline:       "deploy",
+					Group:          "prod",
+					Running:        true,
+					BrokenResource: true,
+					Statuses:       map[string]int{"succeeded": 3, "failed": 1, "started": 1},
+				},
+			},
+		}
+		config = &summary.Config{Cache: summary.NewCache(time.Minute, fetcher.fetch)}
+	})
+
+	JustBeforeEach(func() {
+		mockRecorder = httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "http://example.com/metrics", nil)
+		Router(config).ServeHTTP(mockRecorder, req)
+	})
+
+	Context("when no host has been fetched", func() {
+		It("exports only the metric descriptions", func() {
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring("# TYPE concourse_summary_pipeline_jobs gauge"))
+			Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring("{"))
+		})
+	})
+
+	Context("when a host which isn't configured has been requested", func() {
+		BeforeEach(func() {
+			config.Hosts = []summary.Host{{FQDN: "ci.example.com"}}
+			apiGet(config, "/api/v1/host/127.0.0.1:1")
+		})
+
+		It("doesn't cache or export it", func() {
+			_, ok := config.Cache.Snapshot("127.0.0.1:1")
+			Ω(ok).Should(BeFalse())
+			Ω(mockRecorder.Body.String()).ShouldNot(ContainSubstring("127.0.0.1:1"))
+		})
+	})
+
+	Context("when a host has been fetched", func() {
+		BeforeEach(func() {
+			config.Cache.Refresh("ci.example.com")
+		})
+
+		It("exports the pipeline statuses", func() {
+			body := mockRecorder.Body.String()
+			Ω(mockRecorder.Header().Get("Content-Type")).Should(Equal("text/plain; version=0.0.4"))
+			Ω(body).Should(ContainSubstring(`concourse_summary_pipeline_jobs{host="ci.example.com",team="main",pipeline="deploy",group="prod",status="succeeded"} 3`))
+			Ω(body).Should(ContainSubstring(`concourse_summary_pipeline_jobs{host="ci.example.com",team="main",pipeline="deploy",group="prod",status="failed"} 1`))
+			Ω(body).Should(ContainSubstring(`concourse_summary_pipeline_jobs{host="ci.example.com",team="main",pipeline="deploy",group="prod",status="errored"} 0`))
+			Ω(body).Should(ContainSubstring(`concourse_summary_pipeline_jobs{host="ci.example.com",team="main",pipeline="deploy",group="prod",status="started"} 1`))
+			Ω(body).Should(ContainSubstring(`concourse_summary_pipeline_running{host="ci.example.com",team="main",pipeline="deploy",group="prod"} 1`))
+			Ω(body).Should(ContainSubstring(`concourse_summary_pipeline_paused{host="ci.example.com",team="main",pipeline="deploy",group="prod"} 0`))
+			Ω(body).Should(ContainSubstring(`concourse_summary_pipeline_broken_resource{host="ci.example.com",team="main",pipeline="deploy",group="prod"} 1`))
+		})
+
+		It("exports the collector metrics", func() {
+			body := mockRecorder.Body.String()
+			Ω(body).Should(ContainSubstring(`concourse_summary_host_up{host="ci.example.com"} 1`))
+			Ω(body).Should(ContainSubstring(`concourse_summary_fetches_total{host="ci.example.com"} 1`))
+			Ω(body).Should(ContainSubstring(`concourse_summary_fetch_errors_total{host="ci.example.com"} 0`))
+			Ω(body).Should(ContainSubstring(`# TYPE concourse_summary_fetch_errors_total counter`))
+			Ω(body).Should(MatchRegexp(`concourse_summary_fetch_duration_seconds\{host="ci.example.com"\} \S+`))
+			Ω(body).Should(MatchRegexp(`concourse_summary_last_success_timestamp_seconds\{host="ci.example.com"\} \S+`))
+		})
+
+		It("does not query concourse", func() {
+			Ω(fetcher.Calls("ci.example.com")).Should(Equal(1))
+		})
+
+		Context("and a later fetch fails", func() {
+			BeforeEach(func() {
+				fetcher.err = errors.New("boom")
+				config.Cache.Refresh("ci.example.com")
+			})
+
+			It("counts the error and keeps exporting the last good data", func() {
+				body := mockRecorder.Body.String()
+				Ω(body).Should(ContainSubstring(`concourse_summary_host_up{host="ci.example.com"} 0`))
+				Ω(body).Should(ContainSubstring(`concourse_summary_fetches_total{host="ci.example.com"} 2`))
+				Ω(body).Should(ContainSubstring(`concourse_summary_fetch_errors_total{host="ci.example.com"} 1`))
+				Ω(body).Should(ContainSubstring(`concourse_summary_last_success_timestamp_seconds{host="ci.example.com"}`))
+				Ω(body).Should(ContainSubstring(`status="succeeded"} 3`))
+			})
+		})
+	})
+
+	Context("when a label contains quotes", func() {
+		BeforeEach(func() {
+			fetcher.data[0].Group = `say "hi"`
+			config.Cache.Refresh("ci.example.com")
+		})
+
+		It("escapes the label value", func() {
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring(`group="say \"hi\""`))
+		})
+	})
+})
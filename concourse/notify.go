@@ -0,0 +1,231 @@
+package summary
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Transition is a change in the state of a pipeline group between two snapshots
+type Transition struct {
+	Host     string    `json:"host"`
+	Team     string    `json:"team"`
+	Pipeline string    `json:"pipeline"`
+	Group    string    `json:"group"`
+	From     string    `json:"old_state"`
+	To       string    `json:"new_state"`
+	URL      string    `json:"pipeline_url"`
+	At       time.Time `json:"at"`
+	CSGroups []string  `json:"summary_groups"`
+	Data     Data      `json:"-"`
+}
+
+// Notifier is told about the transitions found in each snapshot of a host
+type Notifier interface {
+	Notify(transitions []Transition) error
+}
+
+// transitionState is the state of a pipeline group for notifications. Unlike State
+// a broken resource is reported, unless a job is already failing or errored
+func transitionState(datum Data) string {
+	state := datum.State()
+	if datum.BrokenResource && (state == "succeeded" || state == "pending") {
+		return "broken"
+	}
+	return state
+}
+
+// including returns the names of the concourse summary groups which show datum
+func (csGroups CSGroups) including(datum Data) []string {
+	var names []string
+	for _, csGroup := range csGroups {
+		if csGroup.shows(datum) {
+			names = append(names, csGroup.Group)
+		}
+	}
+	return names
+}
+
+// maxQueuedNotifications is how many batches of transitions can wait for a notifier
+// before the oldest are dropped
+var maxQueuedNotifications = 1000
+
+// Notifications watches the cache and sends transitions to notifiers. The first
+// snapshot of a host only records its states, as do pipeline groups seen for the
+// first time, so a restart doesn't notify about every pipeline. Each notifier is
+// sent transitions from its own queue, so a slow notifier doesn't hold up polling
+// or the other notifiers
+type Notifications struct {
+	Cache     *Cache
+	CSGroups  CSGroups
+	Notifiers []Notifier
+
+	states        map[string]map[string]string
+	statesMutex   sync.Mutex
+	csGroupsMutex sync.Mutex
+	queues        []*notifierQueue
+	unwatch       func()
+	waitGroup     sync.WaitGroup
+}
+
+// notifierQueue holds the batches of transitions waiting to be sent to a notifier
+type notifierQueue struct {
+	notifier Notifier
+	pending  [][]Transition
+	closed   bool
+	mutex    sync.Mutex
+	queued   chan struct{}
+}
+
+// NewNotifications creates notifications for the hosts cached by config
+func NewNotifications(config *Config, notifiers ...Notifier) *Notifications {
+	return &Notifications{
+		Cache:     config.Cache,
+		CSGroups:  config.CSGroups,
+		Notifiers: notifiers,
+		states:    map[string]map[string]string{},
+	}
+}
+
+// Start begins watching for transitions, it should be started before the cache is
+// first polled
+func (n *Notifications) Start() {
+	n.queues = nil
+	for _, notifier := range n.Notifiers {
+		queue := &notifierQueue{notifier: notifier, queued: make(chan struct{}, 1)}
+		n.queues = append(n.queues, queue)
+		n.waitGroup.Add(1)
+		go func() {
+			defer n.waitGroup.Done()
+			queue.send()
+		}()
+	}
+	n.unwatch = n.Cache.Watch(n.update)
+}
+
+// Stop halts watching and waits for queued notifications to be sent
+func (n *Notifications) Stop() {
+	n.unwatch()
+	for _, queue := range n.queues {
+		queue.close()
+	}
+	n.waitGroup.Wait()
+}
+
+// Reload routes transitions by the concourse summary groups of config from then on,
+// the states already recorded are kept
+func (n *Notifications) Reload(config *Config) {
+	n.csGroupsMutex.Lock()
+	defer n.csGroupsMutex.Unlock()
+
+	n.CSGroups = config.CSGroups
+}
+
+func (n *Notifications) csGroups() CSGroups {
+	n.csGroupsMutex.Lock()
+	defer n.csGroupsMutex.Unlock()
+
+	return n.CSGroups
+}
+
+func (n *Notifications) update(snapshot Snapshot) {
+	if snapshot.Err != nil {
+		return
+	}
+
+	transitions := n.transitions(snapshot)
+	if len(transitions) == 0 {
+		return
+	}
+
+	for _, queue := range n.queues {
+		queue.push(transitions)
+	}
+}
+
+func (q *notifierQueue) push(transitions []Transition) {
+	q.mutex.Lock()
+	if len(q.pending) >= maxQueuedNotifications {
+		fmt.Printf("%T is %d notifications behind, dropping %d transitions\n", q.notifier, len(q.pending), len(q.pending[0]))
+		q.pending = q.pending[1:]
+	}
+	q.pending = append(q.pending, transitions)
+	q.mutex.Unlock()
+
+	q.signal()
+}
+
+func (q *notifierQueue) close() {
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+
+	q.signal()
+}
+
+func (q *notifierQueue) signal() {
+	select {
+	case q.queued <- struct{}{}:
+	default:
+	}
+}
+
+// send notifies each batch of transitions in turn, until the queue is closed and
+// empty
+func (q *notifierQueue) send() {
+	for {
+		q.mutex.Lock()
+		if len(q.pending) == 0 {
+			closed := q.closed
+			q.mutex.Unlock()
+			if closed {
+				return
+			}
+			<-q.queued
+			continue
+		}
+		transitions := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mutex.Unlock()
+
+		if err := q.notifier.Notify(transitions); err != nil {
+			fmt.Println(err.Error())
+		}
+	}
+}
+
+// transitions records the states in snapshot and returns those which changed
+func (n *Notifications) transitions(snapshot Snapshot) []Transition {
+	n.statesMutex.Lock()
+	defer n.statesMutex.Unlock()
+
+	previous, seen := n.states[snapshot.Host]
+	states := map[string]string{}
+
+	csGroups := n.csGroups()
+	var transitions []Transition
+	for _, datum := range snapshot.Data {
+		state := transitionState(datum)
+		states[datum.Key()] = state
+
+		from, ok := previous[datum.Key()]
+		if !seen || !ok || from == state {
+			continue
+		}
+		transitions = append(transitions, Transition{
+			Host:     datum.Host,
+			Team:     datum.Team,
+			Pipeline: datum.Pipeline,
+			Group:    datum.Group,
+			From:     from,
+			To:       state,
+			URL:      datum.URL,
+			At:       snapshot.FetchedAt,
+			CSGroups: csGroups.including(datum),
+			Data:     datum,
+		})
+	}
+
+	n.states[snapshot.Host] = states
+	return transitions
+}
@@ -0,0 +1,336 @@
+package summary_test
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+type fakeNotifier struct {
+	mutex       sync.Mutex
+	transitions [][]summary.Transition
+}
+
+func (f *fakeNotifier) Notify(transitions []summary.Transition) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.transitions = append(f.transitions, transitions)
+	return nil
+}
+
+func (f *fakeNotifier) Transitions() [][]summary.Transition {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return f.transitions
+}
+
+// blockedNotifier holds up every notification until it is released
+type blockedNotifier struct {
+	fakeNotifier
+	release chan struct{}
+}
+
+func (b *blockedNotifier) Notify(transitions []summary.Transition) error {
+	<-b.release
+	return b.fakeNotifier.Notify(transitions)
+}
+
+func pipelineData(pipeline string, statuses map[string]int) summary.Data {
+	return summary.Data{
+		Host:     "ci.example.com",
+		Team:     "main",
+		Pipeline: pipeline,
+		URL:      "https://ci.example.com/teams/main/pipelines/" + pipeline,
+		Statuses: statuses,
+	}
+}
+
+var _ = Describe("Notifications", func() {
+	var (
+		fetcher       *fakeFetcher
+		notifier      *fakeNotifier
+		config        *summary.Config
+		notifications *summary.Notifications
+	)
+
+	setData := func(data ...summary.Data) {
+		fetcher.mutex.Lock()
+		defer fetcher.mutex.Unlock()
+
+		fetcher.data = data
+	}
+
+	BeforeEach(func() {
+		fetcher = &fakeFetcher{}
+		setData(pipelineData("deploy", map[string]int{"succeeded": 2}))
+		notifier = &fakeNotifier{}
+		config = &summary.Config{
+			Cache: summary.NewCache(time.Minute, fetcher.fetch),
+			CSGroups: summary.CSGroups{
+				{Group: "deployers", Hosts: []summary.Host{{FQDN: "ci.example.com", Pipelines: []summary.Pipeline{{Name: "deploy"}}}}},
+				{Group: "everything", Hosts: []summary.Host{{FQDN: "ci.example.com"}}},
+				{Group: "elsewhere", Hosts: []summary.Host{{FQDN: "other.example.com"}}},
+			},
+		}
+		notifications = summary.NewNotifications(config, notifier)
+		notifications.Start()
+	})
+
+	AfterEach(func() {
+		notifications.Stop()
+	})
+
+	It("does not notify about the first snapshot of a host", func() {
+		setData(pipelineData("deploy", map[string]int{"failed": 1}))
+		config.Cache.Refresh("ci.example.com")
+		Consistently(notifier.Transitions).Should(BeEmpty())
+	})
+
+	Context("once a host has been seen", func() {
+		BeforeEach(func() {
+			config.Cache.Refresh("ci.example.com")
+		})
+
+		It("notifies when a pipeline group starts failing", func() {
+			setData(pipelineData("deploy", map[string]int{"succeeded": 1, "failed": 1}))
+			snapshot := config.Cache.Refresh("ci.example.com")
+
+			Eventually(notifier.Transitions).Should(HaveLen(1))
+			transitions := notifier.Transitions()[0]
+			Ω(transitions).Should(HaveLen(1))
+			Ω(transitions[0].Host).Should(Equal("ci.example.com"))
+			Ω(transitions[0].Team).Should(Equal("main"))
+			Ω(transitions[0].Pipeline).Should(Equal("deploy"))
+			Ω(transitions[0].From).Should(Equal("succeeded"))
+			Ω(transitions[0].To).Should(Equal("failed"))
+			Ω(transitions[0].URL).Should(Equal("https://ci.example.com/teams/main/pipelines/deploy"))
+			Ω(transitions[0].At).Should(Equal(snapshot.FetchedAt))
+			Ω(transitions[0].CSGroups).Should(Equal([]string{"deployers", "everything"}))
+		})
+
+		It("notifies when a pipeline group becomes paused or broken", func() {
+			paused := pipelineData("deploy", map[string]int{"succeeded": 2})
+			paused.Paused = true
+			setData(paused)
+			config.Cache.Refresh("ci.example.com")
+			Eventually(notifier.Transitions).Should(HaveLen(1))
+			Ω(notifier.Transitions()[0][0].To).Should(Equal("paused"))
+
+			broken := pipelineData("deploy", map[string]int{"succeeded": 2})
+			broken.BrokenResource = true
+			setData(broken)
+			config.Cache.Refresh("ci.example.com")
+			Eventually(notifier.Transitions).Should(HaveLen(2))
+			Ω(notifier.Transitions()[1][0].From).Should(Equal("paused"))
+			Ω(notifier.Transitions()[1][0].To).Should(Equal("broken"))
+		})
+
+		It("does not notify when the state is unchanged or a pipeline is new", func() {
+			setData(
+				pipelineData("deploy", map[string]int{"succeeded": 3}),
+				pipelineData("release", map[string]int{"failed": 1}),
+			)
+			config.Cache.Refresh("ci.example.com")
+			Consistently(notifier.Transitions).Should(BeEmpty())
+		})
+
+		It("keeps the last states while a host is failing", func() {
+			fetcher.mutex.Lock()
+			fetcher.err = errors.New("boom")
+			fetcher.mutex.Unlock()
+			config.Cache.Refresh("ci.example.com")
+
+			fetcher.mutex.Lock()
+			fetcher.err = nil
+			fetcher.mutex.Unlock()
+			setData(pipelineData("deploy", map[string]int{"errored": 1}))
+			config.Cache.Refresh("ci.example.com")
+
+			Eventually(notifier.Transitions).Should(HaveLen(1))
+			Ω(notifier.Transitions()[0][0].From).Should(Equal("succeeded"))
+			Ω(notifier.Transitions()[0][0].To).Should(Equal("errored"))
+		})
+
+		It("queues every transition for a slow notifier without holding up the others", func() {
+			blocked := &blockedNotifier{release: make(chan struct{})}
+			notifications.Stop()
+			notifications = summary.NewNotifications(config, blocked, notifier)
+			notifications.Start()
+			config.Cache.Refresh("ci.example.com")
+
+			for i := 0; i < 40; i++ {
+				state := "failed"
+				if i%2 == 1 {
+					state = "succeeded"
+				}
+				setData(pipelineData("deploy", map[string]int{state: 1}))
+				config.Cache.Refresh("ci.example.com")
+			}
+
+			Eventually(notifier.Transitions).Should(HaveLen(40))
+			Ω(blocked.Transitions()).Should(BeEmpty())
+
+			close(blocked.release)
+			Eventually(blocked.Transitions).Should(HaveLen(40))
+		})
+	})
+})
+
+type webhookReceiver struct {
+	mutex    sync.Mutex
+	payloads []map[string]interface{}
+	status   int
+}
+
+func (w *webhookReceiver) handler(rw http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	Ω(r.Method).Should(Equal("POST"))
+	Ω(r.Header.Get("Content-Type")).Should(Equal("application/json"))
+	body, _ := ioutil.ReadAll(r.Body)
+	var payload map[string]interface{}
+	Ω(json.Unmarshal(body, &payload)).Should(Succeed())
+	w.payloads = append(w.payloads, payload)
+	rw.WriteHeader(w.status)
+}
+
+var _ = Describe("Webhook", func() {
+	var (
+		receiver    *webhookReceiver
+		hookServer  *httptest.Server
+		webhooks    []*summary.Webhook
+		transitions []summary.Transition
+	)
+
+	BeforeEach(func() {
+		receiver = &webhookReceiver{status: 200}
+		hookServer = httptest.NewServer(http.HandlerFunc(receiver.handler))
+		transitions = []summary.Transition{
+			{
+				Host:     "ci.example.com",
+				Team:     "main",
+				Pipeline: "deploy",
+				Group:    "prod",
+				From:     "succeeded",
+				To:       "failed",
+				URL:      "https://ci.example.com/teams/main/pipelines/deploy?groups=prod",
+				At:       time.Date(2017, 9, 7, 16, 0, 0, 0, time.UTC),
+				CSGroups: []string{"deployers"},
+			},
+			{
+				Host:     "ci.example.com",
+				Pipeline: "release",
+				From:     "failed",
+				To:       "succeeded",
+				CSGroups: []string{"releasers"},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		hookServer.Close()
+	})
+
+	Context("when a webhook isn't limited to groups", func() {
+		BeforeEach(func() {
+			var err error
+			webhooks, err = summary.SetupWebhooks(`[{"url": "` + hookServer.URL + `"}]`)
+			Ω(err).Should(BeNil())
+		})
+
+		It("posts every transition", func() {
+			Ω(webhooks[0].Notify(transitions)).Should(Succeed())
+			Ω(receiver.payloads).Should(HaveLen(2))
+			Ω(receiver.payloads[0]).Should(Equal(map[string]interface{}{
+				"host":           "ci.example.com",
+				"team":           "main",
+				"pipeline":       "deploy",
+				"group":          "prod",
+				"old_state":      "succeeded",
+				"new_state":      "failed",
+				"pipeline_url":   "https://ci.example.com/teams/main/pipelines/deploy?groups=prod",
+				"at":             "2017-09-07T16:00:00Z",
+				"summary_groups": []interface{}{"deployers"},
+			}))
+		})
+
+		Context("and the receiver fails", func() {
+			BeforeEach(func() {
+				receiver.status = 500
+			})
+
+			It("returns an error after trying every transition", func() {
+				Ω(webhooks[0].Notify(transitions)).Should(MatchError(ContainSubstring("2 notifications failed")))
+				Ω(receiver.payloads).Should(HaveLen(2))
+			})
+		})
+	})
+
+	Context("when a webhook's url holds a secret", func() {
+		It("only reports the webhook's host when posting fails", func() {
+			webhooks, err := summary.SetupWebhooks(`[{"url": "http://127.0.0.1:1/hooks/secret-token"}]`)
+			Ω(err).Should(BeNil())
+
+			err = webhooks[0].Notify(transitions)
+			Ω(err).Should(MatchError(HavePrefix("webhook http://127.0.0.1:1: 2 notifications failed")))
+			Ω(err.Error()).ShouldNot(ContainSubstring("secret-token"))
+		})
+
+		It("only reports the webhook's host when the url is invalid", func() {
+			_, err := summary.SetupWebhooks(`[{"url": "ftp://example.com/hooks/secret-token"}]`)
+			Ω(err).Should(MatchError("webhook ftp://example.com: url must be http or https"))
+
+			_, err = summary.SetupWebhooks(`[{"url": "http://example.com/hooks/secret-token%zz"}]`)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).ShouldNot(ContainSubstring("secret-token"))
+		})
+	})
+
+	Context("when a webhook is limited to groups", func() {
+		BeforeEach(func() {
+			var err error
+			webhooks, err = summary.SetupWebhooks(`[{"url": "` + hookServer.URL + `", "groups": ["releasers"]}]`)
+			Ω(err).Should(BeNil())
+		})
+
+		It("only posts transitions shown by those groups", func() {
+			Ω(webhooks[0].Notify(transitions)).Should(Succeed())
+			Ω(receiver.payloads).Should(HaveLen(1))
+			Ω(receiver.payloads[0]["pipeline"]).Should(Equal("release"))
+		})
+	})
+
+	Describe("SetupWebhooks", func() {
+		It("returns no webhooks when none are configured", func() {
+			webhooks, err := summary.SetupWebhooks("")
+			Ω(err).Should(BeNil())
+			Ω(webhooks).Should(BeEmpty())
+		})
+
+		It("rejects invalid json", func() {
+			_, err := summary.SetupWebhooks("[{")
+			Ω(err).ShouldNot(BeNil())
+		})
+
+		It("rejects webhooks without an http url", func() {
+			_, err := summary.SetupWebhooks(`[{"groups": ["a"]}]`)
+			Ω(err).Should(MatchError("webhook url is required"))
+
+			_, err = summary.SetupWebhooks(`[{"url": "ftp://example.com"}]`)
+			Ω(err).Should(MatchError("webhook ftp://example.com: url must be http or https"))
+		})
+	})
+})
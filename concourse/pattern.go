@@ -0,0 +1,84 @@
+package summary
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	patternRegexps      = map[string]*regexp.Regexp{}
+	patternRegexpsMutex sync.Mutex
+)
+
+// matchPattern reports whether name matches pattern. A pattern between slashes is a
+// regular expression, which isn't anchored, and anything else is a glob such as
+// release-*. A pattern always matches the name equal to it, so names which were
+// matched exactly before patterns were added keep matching themselves
+func matchPattern(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+	if isRegexpPattern(pattern) {
+		re, err := patternRegexp(pattern)
+		return err == nil && re.MatchString(name)
+	}
+	matched, err := path.Match(pattern, name)
+	return err == nil && matched
+}
+
+func matchAnyPattern(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// validatePattern reports a pattern which can't be matched
+func validatePattern(pattern string) error {
+	if isRegexpPattern(pattern) {
+		if _, err := patternRegexp(pattern); err != nil {
+			return fmt.Errorf("invalid regular expression %s: %s", pattern, err.Error())
+		}
+		return nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q", pattern)
+	}
+	return nil
+}
+
+// validateNamePattern is validatePattern for the names and groups of pipeline
+// entries, which were exact names before patterns were added, so one which isn't a
+// valid glob is still matched exactly rather than rejected
+func validateNamePattern(pattern string) error {
+	if isRegexpPattern(pattern) {
+		return validatePattern(pattern)
+	}
+	return nil
+}
+
+func isRegexpPattern(pattern string) bool {
+	return len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/")
+}
+
+// patternRegexp compiles the regular expression of a pattern once, as patterns are
+// matched against every pipeline each time a page is served
+func patternRegexp(pattern string) (*regexp.Regexp, error) {
+	patternRegexpsMutex.Lock()
+	defer patternRegexpsMutex.Unlock()
+
+	if re, ok := patternRegexps[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern[1 : len(pattern)-1])
+	if err != nil {
+		return nil, err
+	}
+	patternRegexps[pattern] = re
+	return re, nil
+}
@@ -0,0 +1,92 @@
+package summary_test
+
+import (
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+const patternPipelinesPayload = `[
+  {"id": 1, "name": "release-api", "url": "/release-api.url", "team_name": "main"},
+  {"id": 2, "name": "release-web", "url": "/release-web.url", "team_name": "main"},
+  {"id": 3, "name": "release-legacy", "url": "/release-legacy.url", "team_name": "main"},
+  {"id": 4, "name": "tools", "url": "/tools.url", "team_name": "main"},
+  {"id": 5, "name": "legacy[2]", "url": "/legacy.url", "team_name": "main"}
+]`
+
+var _ = Describe("Pipeline patterns", func() {
+	var config *summary.Config
+
+	// selected returns the pipeline groups shown by a group with a single host
+	// selecting pipelines by the entries given
+	selected := func(pipelines ...summary.Pipeline) []string {
+		config.CSGroups = summary.CSGroups{{Group: "patterns", Hosts: []summary.Host{{FQDN: Host(server), Pipelines: pipelines}}}}
+
+		_, body := apiGet(config, "/api/v1/group/patterns")
+		var names []string
+		for _, pipeline := range body["hosts"].([]interface{})[0].(map[string]interface{})["pipelines"].([]interface{}) {
+			pipeline := pipeline.(map[string]interface{})
+			names = append(names, fmt.Sprintf("%s/%s", pipeline["pipeline"], pipeline["group"]))
+		}
+		return names
+	}
+
+	BeforeEach(func() {
+		routes := []MockRoute{{"GET", "/api/v1/teams/pipelines", patternPipelinesPayload, 200, "", nil}}
+		for _, pipeline := range []string{"release-api", "release-web", "release-legacy", "tools", "legacy[2]"} {
+			routes = append(routes,
+				MockRoute{"GET", fmt.Sprintf("/api/v1/teams/pipelines/%s/jobs", pipeline), groupedJobsPayload, 200, "", nil},
+				MockRoute{"GET", fmt.Sprintf("/api/v1/teams/pipelines/%s/resources", pipeline), "[]", 200, "", nil},
+			)
+		}
+		setupMultiple(routes)
+		config = &summary.Config{Protocol: "http"}
+	})
+
+	AfterEach(func() {
+		teardown()
+	})
+
+	It("matches names by glob, leaving out excluded pipelines", func() {
+		Ω(selected(summary.Pipeline{Name: "release-*", Groups: []string{"deploy"}, Exclude: []string{"*-legacy"}})).Should(Equal([]string{
+			"release-api/deploy",
+			"release-web/deploy",
+		}))
+	})
+
+	It("matches names and groups by regular expressions between slashes", func() {
+		Ω(selected(summary.Pipeline{Name: "/^(release-a|to)/", Groups: []string{"/^b/"}})).Should(Equal([]string{
+			"release-api/build",
+			"tools/build",
+		}))
+	})
+
+	It("matches groups by glob, leaving out excluded groups", func() {
+		Ω(selected(summary.Pipeline{Name: "tools", Groups: []string{"*"}})).Should(Equal([]string{"tools/build", "tools/deploy"}))
+		Ω(selected(summary.Pipeline{Name: "*", ExcludeGroups: []string{"build"}})).Should(Equal([]string{
+			"legacy[2]/deploy",
+			"release-api/deploy",
+			"release-legacy/deploy",
+			"release-web/deploy",
+			"tools/deploy",
+		}))
+	})
+
+	It("shows a pipeline group once however many entries match it", func() {
+		Ω(selected(
+			summary.Pipeline{Name: "tools", Groups: []string{"build"}},
+			summary.Pipeline{Name: "t*"},
+		)).Should(Equal([]string{"tools/build", "tools/deploy"}))
+	})
+
+	It("only matches exact names without wildcards", func() {
+		Ω(selected(summary.Pipeline{Name: "release"})).Should(BeEmpty())
+	})
+
+	It("matches names equal to the pipeline's name as they were before patterns", func() {
+		Ω(selected(summary.Pipeline{Name: "legacy[2]", Groups: []string{"build"}})).Should(Equal([]string{"legacy[2]/build"}))
+	})
+})
@@ -0,0 +1,151 @@
+package summary
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Poller keeps a cache warm by refreshing every configured host on its own schedule
+type Poller struct {
+	Cache    *Cache
+	Hosts    []string
+	Interval time.Duration
+
+	stops     map[string]chan struct{}
+	mutex     sync.Mutex
+	waitGroup sync.WaitGroup
+}
+
+// NewPoller creates a poller for every host referenced by config, refreshing each
+// host once per refresh interval
+func NewPoller(config *Config) *Poller {
+	return &Poller{
+		Cache:    config.Cache,
+		Hosts:    config.pollHosts(),
+		Interval: time.Duration(config.RefreshInterval) * time.Second,
+	}
+}
+
+// Start begins polling each host in its own goroutine
+func (p *Poller) Start() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.stops = map[string]chan struct{}{}
+	for _, host := range p.Hosts {
+		p.start(host)
+	}
+}
+
+// Stop halts polling and waits for in-flight fetches to finish
+func (p *Poller) Stop() {
+	p.mutex.Lock()
+	for _, stop := range p.stops {
+		close(stop)
+	}
+	p.stops = nil
+	p.mutex.Unlock()
+
+	p.waitGroup.Wait()
+}
+
+// Reload polls the hosts of config from then on. Hosts which are still configured
+// carry on polling on their schedule unless the refresh interval changed, so a
+// reload doesn't fetch every host again
+func (p *Poller) Reload(config *Config) {
+	hosts := config.pollHosts()
+	interval := time.Duration(config.RefreshInterval) * time.Second
+
+	if interval != p.Interval {
+		p.Stop()
+		p.Cache.retain(hosts)
+		p.Hosts = hosts
+		p.Interval = interval
+		p.Start()
+		return
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.Hosts = hosts
+	p.Cache.retain(hosts)
+	if p.stops == nil {
+		return
+	}
+
+	polled := map[string]bool{}
+	for _, host := range hosts {
+		polled[host] = true
+		if _, ok := p.stops[host]; !ok {
+			p.start(host)
+		}
+	}
+	for host, stop := range p.stops {
+		if !polled[host] {
+			close(stop)
+			delete(p.stops, host)
+		}
+	}
+}
+
+// start polls a host until it is stopped, mutex must be held
+func (p *Poller) start(host string) {
+	stop := make(chan struct{})
+	p.stops[host] = stop
+	p.waitGroup.Add(1)
+	go p.poll(host, p.Interval, stop)
+}
+
+func (p *Poller) poll(host string, interval time.Duration, stop chan struct{}) {
+	defer p.waitGroup.Done()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		snapshot := p.Cache.Refresh(host)
+		if snapshot.Err != nil {
+			fmt.Println(snapshot.Err.Error())
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (config *Config) pollHosts() []string {
+	var hosts []string
+	seen := map[string]bool{}
+
+	add := func(host string) {
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	for _, host := range config.Hosts {
+		add(host.FQDN)
+	}
+	for _, csGroup := range config.CSGroups {
+		for _, host := range csGroup.Hosts {
+			add(host.FQDN)
+		}
+	}
+	return hosts
+}
+
+// polls reports whether a host is polled, and so cached
+func (config *Config) polls(host string) bool {
+	for _, polled := range config.pollHosts() {
+		if polled == host {
+			return true
+		}
+	}
+	return false
+}
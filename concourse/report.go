@@ -0,0 +1,471 @@
+package summary
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/concourse/atc"
+	"github.com/concourse/go-concourse/concourse"
+	"github.com/gorilla/mux"
+)
+
+// reportWindows are the periods a report covers, the longest decides how far back
+// builds are fetched
+var reportWindows = []reportWindow{
+	{Name: "24h", Duration: 24 * time.Hour},
+	{Name: "7d", Duration: 7 * 24 * time.Hour},
+	{Name: "30d", Duration: 30 * 24 * time.Hour},
+}
+
+var (
+	reportPageSize = 100
+	// reportBuildsMaxAge is how long the builds of a job are reused for, paging
+	// through a month of builds for every job is too slow to do on every request
+	reportBuildsMaxAge = 5 * time.Minute
+)
+
+type reportWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+// redPeriod is a time a pipeline group spent failed or errored, end is zero while
+// it is still red
+type redPeriod struct {
+	Start time.Time
+	End   time.Time
+}
+
+type reportsStruct struct {
+	Header  headerStruct
+	Windows []reportWindow
+	Hosts   []reportHost
+}
+
+type reportHost struct {
+	Host      string           `json:"host"`
+	Error     string           `json:"error,omitempty"`
+	Pipelines []pipelineReport `json:"pipelines"`
+}
+
+type pipelineReport struct {
+	Host     string         `json:"host"`
+	Team     string         `json:"team"`
+	Pipeline string         `json:"pipeline"`
+	Group    string         `json:"group"`
+	URL      string         `json:"pipeline_url"`
+	Windows  []windowReport `json:"windows"`
+}
+
+type windowReport struct {
+	Window      string   `json:"window"`
+	Builds      int      `json:"builds"`
+	Succeeded   int      `json:"succeeded"`
+	SuccessRate *float64 `json:"success_rate"`
+	Failures    int      `json:"failures"`
+	MTTR        *float64 `json:"mttr_seconds"`
+	LongestRed  float64  `json:"longest_red_seconds"`
+}
+
+type cachedBuilds struct {
+	fetchedAt time.Time
+	builds    []atc.Build
+}
+
+// Name identifies the pipeline group within its host
+func (p pipelineReport) Name() string {
+	return ccName(p.Team, p.Pipeline, p.Group)
+}
+
+// SuccessPercent is the success rate as a percentage, a dash when there were no
+// builds
+func (w windowReport) SuccessPercent() string {
+	if w.SuccessRate == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", *w.SuccessRate*100)
+}
+
+// MTTRTime is the mean time to recovery, a dash when nothing recovered
+func (w windowReport) MTTRTime() string {
+	if w.MTTR == nil {
+		return "-"
+	}
+	return duration(seconds(*w.MTTR))
+}
+
+// LongestRedTime is the longest time spent red, a dash when it was never red
+func (w windowReport) LongestRedTime() string {
+	if w.LongestRed == 0 {
+		return "-"
+	}
+	return duration(seconds(w.LongestRed))
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+func buildsKey(host string, job JobData) string {
+	return fmt.Sprintf("%s/%s/%s/%s", host, job.Team, job.Pipeline, job.Name)
+}
+
+func finished(build atc.Build) bool {
+	switch build.Status {
+	case "succeeded", "failed", "errored", "aborted":
+		return build.EndTime > 0
+	}
+	return false
+}
+
+// pageBuilds fetches the builds of a job, newest first, until a page reaches back
+// past since
+func pageBuilds(team concourse.Team, pipeline, job string, since time.Time) ([]atc.Build, error) {
+	var builds []atc.Build
+	page := concourse.Page{Limit: reportPageSize}
+	for {
+		pageBuilds, pagination, found, err := team.JobBuilds(pipeline, job, page)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			return builds, nil
+		}
+		builds = append(builds, pageBuilds...)
+
+		if len(pageBuilds) == 0 || pagination.Next == nil {
+			return builds, nil
+		}
+		oldest := pageBuilds[len(pageBuilds)-1]
+		if oldest.StartTime > 0 && unixTime(oldest.StartTime).Before(since) {
+			return builds, nil
+		}
+		page = *pagination.Next
+	}
+}
+
+// jobBuilds returns the builds of every job of data by team, pipeline and job,
+// fetching those which aren't cached using at most the host's fetch concurrency
+func (config *Config) jobBuilds(host Host, data []Data, since time.Time) (map[string][]atc.Build, error) {
+	var missing []JobData
+	builds := map[string][]atc.Build{}
+
+	caches := config.sharedCaches()
+	caches.reportBuildsMutex.Lock()
+	if caches.reportBuilds == nil {
+		caches.reportBuilds = map[string]cachedBuilds{}
+	}
+	for _, datum := range data {
+		for _, job := range datum.Jobs {
+			key := buildsKey(host.FQDN, job)
+			if _, ok := builds[key]; ok {
+				continue
+			}
+			cached, ok := caches.reportBuilds[key]
+			if ok && time.Since(cached.fetchedAt) < reportBuildsMaxAge {
+				builds[key] = cached.builds
+				continue
+			}
+			builds[key] = nil
+			missing = append(missing, job)
+		}
+	}
+	caches.reportBuildsMutex.Unlock()
+
+	concurrency := host.FetchConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	fetched := make([][]atc.Build, len(missing))
+	errs := make([]error, len(missing))
+	indexes := make(chan int)
+
+	var waitGroup sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		waitGroup.Add(1)
+		go func() {
+			defer waitGroup.Done()
+			for index := range indexes {
+				job := missing[index]
+				client, err := config.client(host, job.Team)
+				if err != nil {
+					errs[index] = err
+					continue
+				}
+				fetched[index], errs[index] = pageBuilds(client.Team(job.Team), job.Pipeline, job.Name, since)
+			}
+		}()
+	}
+
+	for index := range missing {
+		indexes <- index
+	}
+	close(indexes)
+	waitGroup.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	caches.reportBuildsMutex.Lock()
+	defer caches.reportBuildsMutex.Unlock()
+	// jobs which are renamed or removed are never asked for again, so their builds
+	// are dropped once they are too old to be reused
+	for key, cached := range caches.reportBuilds {
+		if now.Sub(cached.fetchedAt) >= reportBuildsMaxAge {
+			delete(caches.reportBuilds, key)
+		}
+	}
+	for i, job := range missing {
+		key := buildsKey(host.FQDN, job)
+		builds[key] = fetched[i]
+		caches.reportBuilds[key] = cachedBuilds{fetchedAt: now, builds: fetched[i]}
+	}
+	return builds, nil
+}
+
+// buildRedPeriods replays the finished builds of a pipeline group's jobs in the
+// order they finished, the pipeline group is red while the latest build of any of
+// its jobs didn't succeed
+func buildRedPeriods(jobs [][]atc.Build) []redPeriod {
+	type event struct {
+		job   int
+		build atc.Build
+	}
+
+	var events []event
+	for job, builds := range jobs {
+		for _, build := range builds {
+			if finished(build) {
+				events = append(events, event{job: job, build: build})
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].build.EndTime < events[j].build.EndTime
+	})
+
+	var periods []redPeriod
+	redJobs := map[int]bool{}
+	for _, e := range events {
+		wasRed := len(redJobs) > 0
+		if e.build.Status == "succeeded" {
+			delete(redJobs, e.job)
+		} else {
+			redJobs[e.job] = true
+		}
+
+		at := unixTime(e.build.EndTime)
+		switch isRed := len(redJobs) > 0; {
+		case isRed && !wasRed:
+			periods = append(periods, redPeriod{Start: at})
+		case !isRed && wasRed:
+			periods[len(periods)-1].End = at
+		}
+	}
+	return periods
+}
+
+// historyRedPeriods are the periods a pipeline group's history samples were failed
+// or errored
+func historyRedPeriods(records []HistoryRecord) []redPeriod {
+	var periods []redPeriod
+	wasRed := false
+	for _, record := range records {
+		isRed := record.State == "failed" || record.State == "errored"
+		switch {
+		case isRed && !wasRed:
+			periods = append(periods, redPeriod{Start: record.At})
+		case !isRed && wasRed:
+			periods[len(periods)-1].End = record.At
+		}
+		wasRed = isRed
+	}
+	return periods
+}
+
+// mergeRedPeriods uses the periods found from builds until the history starts and
+// the history's own from then on, a period spanning the start is joined up
+func mergeRedPeriods(backfill, recent []redPeriod, recentStart time.Time) []redPeriod {
+	if recentStart.IsZero() {
+		return backfill
+	}
+
+	var periods []redPeriod
+	for _, period := range backfill {
+		if !period.Start.Before(recentStart) {
+			break
+		}
+		if period.End.IsZero() || period.End.After(recentStart) {
+			period.End = recentStart
+		}
+		periods = append(periods, period)
+	}
+
+	for _, period := range recent {
+		if last := len(periods) - 1; last >= 0 && periods[last].End.Equal(period.Start) {
+			periods[last].End = period.End
+			continue
+		}
+		periods = append(periods, period)
+	}
+	return periods
+}
+
+func newWindowReport(window reportWindow, builds []atc.Build, periods []redPeriod, now time.Time) windowReport {
+	from := now.Add(-window.Duration)
+	report := windowReport{Window: window.Name}
+
+	for _, build := range builds {
+		if !finished(build) || unixTime(build.EndTime).Before(from) {
+			continue
+		}
+		report.Builds++
+		if build.Status == "succeeded" {
+			report.Succeeded++
+		}
+	}
+	if report.Builds > 0 {
+		rate := float64(report.Succeeded) / float64(report.Builds)
+		report.SuccessRate = &rate
+	}
+
+	var (
+		recovered int
+		recovery  time.Duration
+	)
+	for _, period := range periods {
+		end := period.End
+		if end.IsZero() {
+			end = now
+		}
+		if end.Before(from) {
+			continue
+		}
+
+		if !period.Start.Before(from) {
+			report.Failures++
+		}
+		if !period.End.IsZero() {
+			recovered++
+			recovery += period.End.Sub(period.Start)
+		}
+
+		start := period.Start
+		if start.Before(from) {
+			start = from
+		}
+		if red := end.Sub(start).Seconds(); red > report.LongestRed {
+			report.LongestRed = red
+		}
+	}
+	if recovered > 0 {
+		mttr := (recovery / time.Duration(recovered)).Seconds()
+		report.MTTR = &mttr
+	}
+	return report
+}
+
+// hostReport reports on the pipeline groups in data, using the history for the
+// time since it started and builds before that
+func (config *Config) hostReport(host string, data []Data, now time.Time) reportHost {
+	report := reportHost{Host: host, Pipelines: []pipelineReport{}}
+
+	since := now.Add(-reportWindows[len(reportWindows)-1].Duration)
+	builds, err := config.jobBuilds(config.host(host), data, since)
+	if err != nil {
+		fmt.Println(err.Error())
+		report.Error = errorClass(err)
+		return report
+	}
+
+	for _, datum := range data {
+		var (
+			jobs      [][]atc.Build
+			allBuilds []atc.Build
+		)
+		for _, job := range datum.Jobs {
+			jobBuilds := builds[buildsKey(host, job)]
+			jobs = append(jobs, jobBuilds)
+			allBuilds = append(allBuilds, jobBuilds...)
+		}
+
+		periods := buildRedPeriods(jobs)
+		if config.History != nil {
+			records, err := config.History.Records(datum.Key(), time.Time{}, now)
+			if err != nil {
+				fmt.Println(err.Error())
+			} else if len(records) > 0 {
+				periods = mergeRedPeriods(periods, historyRedPeriods(records), records[0].At)
+			}
+		}
+
+		pipeline := pipelineReport{
+			Host:     datum.Host,
+			Team:     datum.Team,
+			Pipeline: datum.Pipeline,
+			Group:    datum.Group,
+			URL:      datum.URL,
+		}
+		for _, window := range reportWindows {
+			pipeline.Windows = append(pipeline.Windows, newWindowReport(window, allBuilds, periods, now))
+		}
+		report.Pipelines = append(report.Pipelines, pipeline)
+	}
+	return report
+}
+
+// groupReport reports on every host of a concourse summary group, hosts which fail
+// are reported with an error rather than failing the whole group
+func (config *Config) groupReport(csGroup CSGroup, now time.Time) []reportHost {
+	var reports []reportHost
+	for _, groupData := range config.groupData(csGroup) {
+		if groupData.Error != "" {
+			reports = append(reports, reportHost{Host: groupData.Host, Error: groupData.Error, Pipelines: []pipelineReport{}})
+			continue
+		}
+		reports = append(reports, config.hostReport(groupData.Host, groupData.Statuses, now))
+	}
+	return reports
+}
+
+func (config *Config) writeReports(w http.ResponseWriter, hosts []reportHost) {
+	err := config.Templates.ExecuteTemplate(w, "reports", reportsStruct{
+		Header: headerStruct{
+			RefreshInterval: config.RefreshInterval,
+		},
+		Windows: reportWindows,
+		Hosts:   hosts,
+	})
+	if err != nil {
+		panic(err.Error())
+	}
+}
+
+// HostReport renders and serves the reliability report of a host
+func (config *Config) HostReport(w http.ResponseWriter, r *http.Request) {
+	host := mux.Vars(r)["host"]
+
+	snapshot := config.hostSnapshot(host)
+	if snapshot.Err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "Error collecting data from concourse (%s) please refer to logs for more details", host)
+		fmt.Println(snapshot.Err.Error())
+		return
+	}
+
+	config.writeReports(w, []reportHost{config.hostReport(host, snapshot.Data, time.Now())})
+}
+
+// GroupReport renders and serves the reliability report of a concourse summary group
+func (config *Config) GroupReport(w http.ResponseWriter, r *http.Request) {
+	csGroup := config.CSGroups.group(mux.Vars(r)["group"])
+	config.writeReports(w, config.groupReport(csGroup, time.Now()))
+}
@@ -0,0 +1,237 @@
+package summary_test
+
+import (
+	"fmt"
+	"html/template"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/gorilla/mux"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+type fakeBuild struct {
+	id     int
+	status string
+	ended  time.Duration
+}
+
+func buildsPayload(now time.Time, builds ...fakeBuild) string {
+	var payload []string
+	for _, build := range builds {
+		end := now.Add(-build.ended).Unix()
+		payload = append(payload, fmt.Sprintf(`{"id": %d, "name": "%d", "status": "%s", "start_time": %d, "end_time": %d}`, build.id, build.id, build.status, end-60, end))
+	}
+	return "[" + strings.Join(payload, ",") + "]"
+}
+
+var _ = Describe("Reports", func() {
+	var (
+		config       *summary.Config
+		reportServer *httptest.Server
+		now          time.Time
+		buildPages   int
+	)
+
+	BeforeEach(func() {
+		now = time.Now()
+		buildPages = 0
+		day := 24 * time.Hour
+
+		router := mux.NewRouter()
+		router.HandleFunc("/api/v1/teams/pipelines", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, pipelinesPayload)
+		})
+		router.HandleFunc("/api/v1/teams/pipelines/test1/jobs", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, buildsJobsPayload)
+		})
+		router.HandleFunc("/api/v1/teams/pipelines/test1/resources", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, "[]")
+		})
+		router.HandleFunc("/api/v1/teams/pipelines/test1/jobs/unit/builds", func(w http.ResponseWriter, r *http.Request) {
+			buildPages++
+			if r.URL.Query().Get("since") == "" {
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s/api/v1/teams/main/pipelines/test1/jobs/unit/builds?since=3&limit=100>; rel="next"`, r.Host))
+				fmt.Fprint(w, buildsPayload(now,
+					fakeBuild{6, "started", 0},
+					fakeBuild{5, "succeeded", time.Hour},
+					fakeBuild{4, "failed", 2 * time.Hour},
+					fakeBuild{3, "failed", 3 * time.Hour},
+				))
+				return
+			}
+			fmt.Fprint(w, buildsPayload(now,
+				fakeBuild{2, "succeeded", 10 * day},
+				fakeBuild{1, "failed", 40 * day},
+			))
+		})
+		router.HandleFunc("/api/v1/teams/pipelines/test1/jobs/deploy/builds", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, buildsPayload(now,
+				fakeBuild{2, "failed", 30 * time.Minute},
+				fakeBuild{1, "succeeded", 5 * time.Hour},
+			))
+		})
+		router.NotFoundHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			defer GinkgoRecover()
+			Fail(fmt.Sprintf("Route requested but not mocked: %s", r.URL))
+		})
+		reportServer = httptest.NewServer(router)
+
+		config = &summary.Config{
+			Protocol:  "http",
+			Templates: template.Must(template.ParseGlob("../templates/*")),
+			CSGroups: []summary.CSGroup{
+				{Group: "builders", Hosts: []summary.Host{{FQDN: Host(reportServer), Pipelines: []summary.Pipeline{{Name: "test1", Groups: []string{"build"}}}}}},
+				{Group: "offline", Hosts: []summary.Host{{FQDN: "127.0.0.1:1"}}},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		reportServer.Close()
+	})
+
+	windows := func(pipeline interface{}) map[string]map[string]interface{} {
+		windows := map[string]map[string]interface{}{}
+		for _, window := range pipeline.(map[string]interface{})["windows"].([]interface{}) {
+			window := window.(map[string]interface{})
+			windows[window["window"].(string)] = window
+		}
+		return windows
+	}
+
+	Describe("/api/v1/reports/host/{host}", func() {
+		It("reports success rate, failures, recovery time and the longest red streak over each window", func() {
+			mockRecorder, body := apiGet(config, fmt.Sprintf("/api/v1/reports/host/%s", Host(reportServer)))
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(body["version"]).Should(Equal("v1"))
+			Ω(body["host"]).Should(Equal(Host(reportServer)))
+
+			pipelines := body["pipelines"].([]interface{})
+			Ω(pipelines).Should(HaveLen(2))
+			Ω(pipelines[0].(map[string]interface{})["group"]).Should(Equal("build"))
+
+			build := windows(pipelines[0])
+			Ω(build["24h"]["builds"]).Should(Equal(float64(3)))
+			Ω(build["24h"]["succeeded"]).Should(Equal(float64(1)))
+			Ω(build["24h"]["success_rate"]).Should(BeNumerically("~", 1.0/3, 0.001))
+			Ω(build["24h"]["failures"]).Should(Equal(float64(1)))
+			Ω(build["24h"]["mttr_seconds"]).Should(Equal(float64(7200)))
+			Ω(build["24h"]["longest_red_seconds"]).Should(Equal(float64(7200)))
+
+			Ω(build["30d"]["builds"]).Should(Equal(float64(4)))
+			Ω(build["30d"]["success_rate"]).Should(Equal(0.5))
+			// the red streak which began before the window still ended within it
+			Ω(build["30d"]["failures"]).Should(Equal(float64(1)))
+			Ω(build["30d"]["mttr_seconds"]).Should(Equal(float64((30*24*3600 + 7200) / 2)))
+			Ω(build["30d"]["longest_red_seconds"]).Should(BeNumerically("~", 20*24*3600, 5))
+
+			deploy := windows(pipelines[1])
+			Ω(deploy["24h"]["success_rate"]).Should(Equal(0.5))
+			Ω(deploy["24h"]["failures"]).Should(Equal(float64(1)))
+			Ω(deploy["24h"]["mttr_seconds"]).Should(BeNil())
+			Ω(deploy["24h"]["longest_red_seconds"]).Should(BeNumerically("~", 1800, 5))
+		})
+
+		It("reuses the builds it has fetched", func() {
+			apiGet(config, fmt.Sprintf("/api/v1/reports/host/%s", Host(reportServer)))
+			Ω(buildPages).Should(Equal(2))
+
+			apiGet(config, fmt.Sprintf("/api/v1/reports/host/%s", Host(reportServer)))
+			Ω(buildPages).Should(Equal(2))
+		})
+
+		Context("with a history", func() {
+			var dir string
+
+			BeforeEach(func() {
+				var err error
+				dir, err = ioutil.TempDir("", "report")
+				Ω(err).Should(BeNil())
+				config.History, err = summary.SetupHistory(`{"path": "` + filepath.Join(dir, "history.db") + `"}`)
+				Ω(err).Should(BeNil())
+			})
+
+			AfterEach(func() {
+				config.History.Stop()
+				os.RemoveAll(dir)
+			})
+
+			It("uses the history from when it starts", func() {
+				datum := func(status string) summary.Data {
+					return summary.Data{Host: Host(reportServer), Pipeline: "test1", Group: "build", Statuses: map[string]int{status: 1}}
+				}
+				Ω(config.History.Record(summary.Snapshot{Data: []summary.Data{datum("failed")}, FetchedAt: now.Add(-150 * time.Minute)})).Should(Succeed())
+				Ω(config.History.Record(summary.Snapshot{Data: []summary.Data{datum("succeeded")}, FetchedAt: now.Add(-30 * time.Minute)})).Should(Succeed())
+
+				_, body := apiGet(config, fmt.Sprintf("/api/v1/reports/host/%s", Host(reportServer)))
+				build := windows(body["pipelines"].([]interface{})[0])
+				// red from the failed build 3 hours ago until the history saw it recover
+				Ω(build["24h"]["mttr_seconds"]).Should(BeNumerically("~", 150*60, 1))
+				Ω(build["24h"]["failures"]).Should(Equal(float64(1)))
+			})
+		})
+	})
+
+	Describe("/api/v1/reports/group/{group}", func() {
+		It("reports on the pipelines selected by the group", func() {
+			mockRecorder, body := apiGet(config, "/api/v1/reports/group/builders")
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(body["group"]).Should(Equal("builders"))
+
+			hosts := body["hosts"].([]interface{})
+			Ω(hosts).Should(HaveLen(1))
+			Ω(hosts[0].(map[string]interface{})["pipelines"]).Should(HaveLen(1))
+		})
+
+		It("includes hosts which fail with their error", func() {
+			mockRecorder, body := apiGet(config, "/api/v1/reports/group/offline")
+			Ω(mockRecorder.Code).Should(Equal(200))
+
+			hosts := body["hosts"].([]interface{})
+			Ω(hosts[0].(map[string]interface{})["error"]).Should(Equal("connection error"))
+			Ω(hosts[0].(map[string]interface{})["pipelines"]).Should(BeEmpty())
+		})
+
+		It("returns a 404 for an unknown group", func() {
+			mockRecorder, body := apiGet(config, "/api/v1/reports/group/missing")
+			Ω(mockRecorder.Code).Should(Equal(404))
+			Ω(body["error"]).Should(Equal("group (missing) not found"))
+		})
+	})
+
+	Describe("/reports/host/{host}", func() {
+		It("renders a table of each pipeline group over each window", func() {
+			mockRecorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", fmt.Sprintf("http://example.com/reports/host/%s", Host(reportServer)), nil)
+			Router(config).ServeHTTP(mockRecorder, req)
+
+			Ω(mockRecorder.Code).Should(Equal(200))
+			body := stringMinifier(mockRecorder.Body.String())
+			Ω(body).Should(ContainSubstring(stringMinifier(`<th colspan="4">24h</th><th colspan="4">7d</th><th colspan="4">30d</th>`)))
+			Ω(body).Should(ContainSubstring(stringMinifier(fmt.Sprintf(`<td><a href="http://%s/test1.url?groups=build" target="_blank">test1/build</a></td>
+				<td>33%%</td><td>1</td><td>2h 0m</td><td>2h 0m</td>`, Host(reportServer)))))
+			Ω(body).Should(ContainSubstring(stringMinifier(`<td>50%</td><td>1</td><td>-</td><td>30m</td>`)))
+		})
+	})
+
+	Describe("/reports/group/{group}", func() {
+		It("shows hosts which fail with their error", func() {
+			mockRecorder := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "http://example.com/reports/group/offline", nil)
+			Router(config).ServeHTTP(mockRecorder, req)
+
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(mockRecorder.Body.String()).Should(ContainSubstring("Error collecting builds (connection error)"))
+		})
+	})
+})
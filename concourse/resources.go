@@ -0,0 +1,78 @@
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/concourse/atc"
+	"github.com/concourse/go-concourse/concourse"
+	"github.com/tedsuo/rata"
+)
+
+// BrokenResource is a pipeline resource which concourse is failing to check
+type BrokenResource struct {
+	Name       string `json:"name"`
+	CheckError string `json:"check_error"`
+}
+
+// listResources fetches the resources of a pipeline, the vendored go-concourse
+// client doesn't expose the ListResources route so the request is made directly
+func listResources(client concourse.Client, team, pipeline string) ([]atc.Resource, error) {
+	request, err := rata.NewRequestGenerator(client.URL(), atc.Routes).CreateRequest(atc.ListResources, rata.Params{
+		"team_name":     team,
+		"pipeline_name": pipeline,
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := client.HTTPClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusUnauthorized:
+		return nil, concourse.ErrUnauthorized
+	case http.StatusForbidden:
+		return nil, concourse.ErrForbidden
+	default:
+		body, _ := ioutil.ReadAll(response.Body)
+		return nil, fmt.Errorf("Unexpected Response\nStatus: %s\nBody:\n%s", response.Status, body)
+	}
+
+	var resources []atc.Resource
+	if err := json.NewDecoder(response.Body).Decode(&resources); err != nil {
+		return nil, err
+	}
+	return resources, nil
+}
+
+// brokenResources returns the failing resources which affect a pipeline group,
+// resources outside of any group affect every group
+func brokenResources(resources []atc.Resource, group string) []BrokenResource {
+	var broken []BrokenResource
+	for _, resource := range resources {
+		if !resource.FailingToCheck {
+			continue
+		}
+		if group != "" && len(resource.Groups) > 0 && !contains(resource.Groups, group) {
+			continue
+		}
+		broken = append(broken, BrokenResource{Name: resource.Name, CheckError: resource.CheckError})
+	}
+	return broken
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
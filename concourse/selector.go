@@ -0,0 +1,98 @@
+package summary
+
+// Selector picks out pipeline groups by their attributes each time their host is
+// fetched, so a concourse summary group follows pipelines as they are added, paused
+// or made public. Every attribute given must match, hosts, teams, names and groups
+// are patterns, see matchPattern
+type Selector struct {
+	Hosts         []string `json:"hosts,omitempty"`
+	Teams         []string `json:"teams,omitempty"`
+	Names         []string `json:"names,omitempty"`
+	Exclude       []string `json:"exclude,omitempty"`
+	Groups        []string `json:"groups,omitempty"`
+	ExcludeGroups []string `json:"exclude_groups,omitempty"`
+	Paused        *bool    `json:"paused,omitempty"`
+	Public        *bool    `json:"public,omitempty"`
+}
+
+// matchesHost reports whether the selector could match pipelines of a host
+func (s Selector) matchesHost(fqdn string) bool {
+	return len(s.Hosts) == 0 || matchAnyPattern(s.Hosts, fqdn)
+}
+
+func (s Selector) matches(datum Data) bool {
+	if !s.matchesHost(datum.Host) {
+		return false
+	}
+	if len(s.Teams) > 0 && !matchAnyPattern(s.Teams, datum.Team) {
+		return false
+	}
+	if len(s.Names) > 0 && !matchAnyPattern(s.Names, datum.Pipeline) {
+		return false
+	}
+	if matchAnyPattern(s.Exclude, datum.Pipeline) {
+		return false
+	}
+	if !(Pipeline{Groups: s.Groups, ExcludeGroups: s.ExcludeGroups}).matchesGroup(datum) {
+		return false
+	}
+	if s.Paused != nil && *s.Paused != datum.Paused {
+		return false
+	}
+	return s.Public == nil || *s.Public == datum.Public
+}
+
+// groupHosts returns the hosts a concourse summary group shows pipelines from. A
+// group with selectors but no hosts of its own shows every configured host its
+// selectors could match
+func (config *Config) groupHosts(csGroup CSGroup) []Host {
+	if len(csGroup.Hosts) > 0 || len(csGroup.Select) == 0 {
+		return csGroup.Hosts
+	}
+
+	var hosts []Host
+	for _, fqdn := range config.pollHosts() {
+		for _, selector := range csGroup.Select {
+			if selector.matchesHost(fqdn) {
+				hosts = append(hosts, Host{FQDN: fqdn})
+				break
+			}
+		}
+	}
+	return hosts
+}
+
+// selected returns the data matching any of the group's selectors, all of it when
+// the group has none
+func (csGroup CSGroup) selected(data []Data) []Data {
+	if len(csGroup.Select) == 0 {
+		return data
+	}
+
+	var selected []Data
+	for _, datum := range data {
+		for _, selector := range csGroup.Select {
+			if selector.matches(datum) {
+				selected = append(selected, datum)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// shows reports whether the group shows datum, wherever it was fetched from
+func (csGroup CSGroup) shows(datum Data) bool {
+	if len(csGroup.selected([]Data{datum})) == 0 {
+		return false
+	}
+	if len(csGroup.Hosts) == 0 {
+		return len(csGroup.Select) > 0
+	}
+	for _, host := range csGroup.Hosts {
+		if host.FQDN == datum.Host && len(filterData([]Data{datum}, host.Pipelines)) > 0 {
+			return true
+		}
+	}
+	return false
+}
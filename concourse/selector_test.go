@@ -0,0 +1,116 @@
+package summary_test
+
+import (
+	"fmt"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+const selectorPipelinesPayload = `[
+  {"id": 1, "name": "release-api", "url": "/release-api.url", "public": true, "paused": false, "team_name": "main"},
+  {"id": 2, "name": "release-web", "url": "/release-web.url", "public": false, "paused": true, "team_name": "main"},
+  {"id": 3, "name": "tools", "url": "/tools.url", "public": true, "paused": true, "team_name": "main"}
+]`
+
+var _ = Describe("Selectors", func() {
+	var (
+		config *summary.Config
+		yes    = true
+		no     = false
+	)
+
+	// selected returns the pipeline groups shown by a group, by host
+	selected := func(csGroup summary.CSGroup) map[string][]string {
+		csGroup.Group = "selected"
+		config.CSGroups = summary.CSGroups{csGroup}
+
+		_, body := apiGet(config, "/api/v1/group/selected")
+		hosts := map[string][]string{}
+		for _, host := range body["hosts"].([]interface{}) {
+			host := host.(map[string]interface{})
+			names := []string{}
+			for _, pipeline := range host["pipelines"].([]interface{}) {
+				pipeline := pipeline.(map[string]interface{})
+				names = append(names, fmt.Sprintf("%s/%s", pipeline["pipeline"], pipeline["group"]))
+			}
+			hosts[host["host"].(string)] = names
+		}
+		return hosts
+	}
+
+	BeforeEach(func() {
+		routes := []MockRoute{{"GET", "/api/v1/teams/pipelines", selectorPipelinesPayload, 200, "", nil}}
+		for _, pipeline := range []string{"release-api", "release-web", "tools"} {
+			routes = append(routes,
+				MockRoute{"GET", fmt.Sprintf("/api/v1/teams/pipelines/%s/jobs", pipeline), groupedJobsPayload, 200, "", nil},
+				MockRoute{"GET", fmt.Sprintf("/api/v1/teams/pipelines/%s/resources", pipeline), "[]", 200, "", nil},
+			)
+		}
+		setupMultiple(routes)
+		config = &summary.Config{Protocol: "http", Hosts: []summary.Host{{FQDN: Host(server)}}}
+	})
+
+	AfterEach(func() {
+		teardown()
+	})
+
+	It("selects pipelines across every configured host without listing them", func() {
+		Ω(selected(summary.CSGroup{Select: []summary.Selector{{Paused: &yes, Groups: []string{"deploy"}}}})).Should(Equal(map[string][]string{
+			Host(server): {"release-web/deploy", "tools/deploy"},
+		}))
+	})
+
+	It("only fetches the hosts a selector could match", func() {
+		config.Hosts = append(config.Hosts, summary.Host{FQDN: "127.0.0.1:1"})
+
+		Ω(selected(summary.CSGroup{Select: []summary.Selector{{Hosts: []string{Host(server)}, Public: &yes}}})).Should(Equal(map[string][]string{
+			Host(server): {"release-api/build", "release-api/deploy", "tools/build", "tools/deploy"},
+		}))
+	})
+
+	It("shows pipelines matching any selector, each matching every attribute given", func() {
+		Ω(selected(summary.CSGroup{Select: []summary.Selector{
+			{Names: []string{"release-*"}, Paused: &no},
+			{Names: []string{"/^t/"}, ExcludeGroups: []string{"build"}},
+		}})).Should(Equal(map[string][]string{
+			Host(server): {"release-api/build", "release-api/deploy", "tools/deploy"},
+		}))
+	})
+
+	It("narrows the pipelines of a group's own hosts", func() {
+		Ω(selected(summary.CSGroup{
+			Hosts:  []summary.Host{{FQDN: Host(server), Pipelines: []summary.Pipeline{{Name: "release-*", Groups: []string{"build"}}}}},
+			Select: []summary.Selector{{Public: &no}},
+		})).Should(Equal(map[string][]string{
+			Host(server): {"release-web/build"},
+		}))
+	})
+
+	It("routes notifications for the pipelines it selects", func() {
+		fetcher := &fakeFetcher{data: []summary.Data{pipelineData("deploy", map[string]int{"succeeded": 1})}}
+		notifier := &fakeNotifier{}
+		config := &summary.Config{
+			Cache: summary.NewCache(time.Minute, fetcher.fetch),
+			CSGroups: summary.CSGroups{
+				{Group: "production", Select: []summary.Selector{{Hosts: []string{"ci.*"}, Paused: &no}}},
+				{Group: "paused", Select: []summary.Selector{{Paused: &yes}}},
+			},
+		}
+		notifications := summary.NewNotifications(config, notifier)
+		notifications.Start()
+		defer notifications.Stop()
+
+		config.Cache.Refresh("ci.example.com")
+		fetcher.mutex.Lock()
+		fetcher.data = []summary.Data{pipelineData("deploy", map[string]int{"failed": 1})}
+		fetcher.mutex.Unlock()
+		config.Cache.Refresh("ci.example.com")
+
+		Eventually(notifier.Transitions).Should(HaveLen(1))
+		Ω(notifier.Transitions()[0][0].CSGroups).Should(Equal([]string{"production"}))
+	})
+})
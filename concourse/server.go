@@ -2,13 +2,22 @@ package summary
 
 import (
 	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gorilla/mux"
 )
 
 // Server struct
 type Server struct {
+	// Config is the config the server started with, Reload swaps in replacements
 	Config *Config
+
+	reloaded    atomic.Value
+	reloads     []func(*Config)
+	reloadMutex sync.Mutex
+	groups      *GroupStore
 }
 
 // CreateServer - creates a server
@@ -20,10 +29,115 @@ func CreateServer(config *Config) *Server {
 func (s *Server) Start() *mux.Router {
 	router := mux.NewRouter()
 
-	router.HandleFunc("/", s.Config.Index)
-	router.HandleFunc("/host/{host}", s.Config.HostSummary)
-	router.HandleFunc("/group/{group}", s.Config.GroupSummary)
+	router.HandleFunc("/", s.handle((*Config).Index))
+	router.HandleFunc("/host/{host}", s.handle((*Config).HostSummary))
+	router.HandleFunc("/host/{host}/pipeline/{pipeline}", s.handle((*Config).JobsSummary))
+	router.HandleFunc("/host/{host}/cc.xml", s.handle((*Config).HostCCTray))
+	router.HandleFunc("/host/{host}/events", s.HostEvents)
+	router.HandleFunc("/group/{group}", s.handle((*Config).GroupSummary))
+	router.HandleFunc("/group/{group}/cc.xml", s.handle((*Config).GroupCCTray))
+	router.HandleFunc("/group/{group}/events", s.GroupEvents)
+	router.HandleFunc("/reports/host/{host}", s.handle((*Config).HostReport))
+	router.HandleFunc("/reports/group/{group}", s.handle((*Config).GroupReport))
+	router.HandleFunc("/badge/group/{group}.svg", s.handle((*Config).GroupBadge))
+	router.HandleFunc("/badge/{host}/{pipeline}.svg", s.handle((*Config).PipelineBadge))
+	router.HandleFunc("/api/v1/hosts", s.handle((*Config).APIHosts))
+	router.HandleFunc("/api/v1/host/{host}", s.handle((*Config).APIHostSummary))
+	router.HandleFunc("/api/v1/host/{host}/pipeline/{pipeline}", s.handle((*Config).APIJobsSummary))
+	router.HandleFunc("/api/v1/group/{group}", s.handle((*Config).APIGroupSummary))
+	router.HandleFunc("/api/v1/reports/host/{host}", s.handle((*Config).APIHostReport))
+	router.HandleFunc("/api/v1/reports/group/{group}", s.handle((*Config).APIGroupReport))
+	if s.groups != nil {
+		router.HandleFunc("/api/v1/groups", s.authorize(s.APIGroups)).Methods("GET")
+		router.HandleFunc("/api/v1/groups/{group}", s.authorize(s.APIGroupConfig)).Methods("GET")
+		router.HandleFunc("/api/v1/groups/{group}", s.authorize(s.APIPutGroup)).Methods("PUT")
+		router.HandleFunc("/api/v1/groups/{group}", s.authorize(s.APIDeleteGroup)).Methods("DELETE")
+	}
+	router.HandleFunc("/metrics", s.handle((*Config).Metrics))
 	router.PathPrefix("/").Handler(http.FileServer(http.Dir("./assets/")))
 
 	return router
 }
+
+// handle serves each request with the config current when it arrives, so a reload
+// never changes the config part way through a request
+func (s *Server) handle(handler func(*Config, http.ResponseWriter, *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		handler(s.current(), w, r)
+	}
+}
+
+func (s *Server) current() *Config {
+	if config, ok := s.reloaded.Load().(*Config); ok {
+		return config
+	}
+	return s.Config
+}
+
+// OnReload registers functions which are called with every config swapped in by
+// Reload, or by a change to the stored groups, for anything running in the
+// background which holds on to a config
+func (s *Server) OnReload(reloads ...func(*Config)) {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	s.reloads = append(s.reloads, reloads...)
+}
+
+// Reload swaps config in for the running config. The templates, cache and history
+// of the running config carry on with it, so snapshots, subscribers and recorded
+// states aren't lost, and the cache fetches with the new config from then on
+func (s *Server) Reload(config *Config) {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	s.reload(config)
+}
+
+// StoreGroups shows the groups kept in store after the configured groups and serves
+// the groups API which changes them, it must be called before Start
+func (s *Server) StoreGroups(store *GroupStore) {
+	s.groups = store
+	s.reloadGroups()
+}
+
+// reloadGroups swaps in a copy of the running config with the stored groups as they
+// are now. Everything else about the config is kept, including its http clients and
+// caches, so only the pollers of hosts added or removed with a group change
+func (s *Server) reloadGroups() {
+	s.reloadMutex.Lock()
+	defer s.reloadMutex.Unlock()
+
+	running := s.current()
+	reloaded := *running
+	reloaded.caches = running.sharedCaches()
+	reloaded.CSGroups, reloaded.storedGroups = s.groups.merge(running.configuredGroups())
+	if reloaded.Cache != nil {
+		reloaded.Cache.reconfigure(2*time.Duration(reloaded.RefreshInterval)*time.Second, reloaded.fetch)
+	}
+	s.reloaded.Store(&reloaded)
+
+	for _, reload := range s.reloads {
+		reload(&reloaded)
+	}
+}
+
+// reload swaps config in, reloadMutex must be held
+func (s *Server) reload(config *Config) {
+	if s.groups != nil {
+		config.CSGroups, config.storedGroups = s.groups.merge(config.CSGroups)
+	}
+
+	running := s.current()
+	config.Templates = running.Templates
+	config.History = running.History
+	if running.Cache != nil {
+		config.Cache = running.Cache
+		config.Cache.reconfigure(2*time.Duration(config.RefreshInterval)*time.Second, config.fetch)
+	}
+	s.reloaded.Store(config)
+
+	for _, reload := range s.reloads {
+		reload(config)
+	}
+}
@@ -0,0 +1,304 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	defaultSlackBatchWindow    = 10 * time.Second
+	defaultSlackMaxAttachments = 10
+)
+
+var stateColors = map[string]string{
+	"succeeded": "#2ECC71",
+	"failed":    "#E74C3C",
+	"errored":   "#E67E21",
+	"broken":    "#8F4B2D",
+	"paused":    "#3498DB",
+	"pending":   "#5C6C7D",
+}
+
+// Slack posts transitions to Slack or Mattermost incoming webhooks. Transitions are
+// batched for a short window so that a mass outage is sent as one message per
+// destination rather than one per pipeline
+type Slack struct {
+	WebhookURL     string       `json:"webhook_url"`
+	Channel        string       `json:"channel"`
+	Username       string       `json:"username"`
+	Routes         []SlackRoute `json:"routes"`
+	BatchWindow    string       `json:"batch_window"`
+	MaxAttachments int          `json:"max_attachments"`
+
+	batchWindow time.Duration
+	client      *http.Client
+	mutex       sync.Mutex
+	pending     map[slackDestination][]Transition
+	timer       *time.Timer
+}
+
+// SlackRoute sends the transitions it matches to its own webhook or channel. Every
+// criteria given must match, routes are tried in order and the first to match wins
+type SlackRoute struct {
+	Group      string `json:"group"`
+	Host       string `json:"host"`
+	Team       string `json:"team"`
+	Pipeline   string `json:"pipeline"`
+	WebhookURL string `json:"webhook_url"`
+	Channel    string `json:"channel"`
+}
+
+type slackDestination struct {
+	webhookURL string
+	channel    string
+}
+
+type slackMessage struct {
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Text        string            `json:"text"`
+	Attachments []slackAttachment `json:"attachments"`
+}
+
+type slackAttachment struct {
+	Color     string       `json:"color"`
+	Fallback  string       `json:"fallback"`
+	Title     string       `json:"title,omitempty"`
+	TitleLink string       `json:"title_link,omitempty"`
+	Text      string       `json:"text,omitempty"`
+	Blocks    []slackBlock `json:"blocks"`
+}
+
+type slackBlock struct {
+	Type string    `json:"type"`
+	Text slackText `json:"text"`
+}
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// SetupSlack parses the Slack notifier configuration, nil is returned when it isn't
+// configured
+func SetupSlack(slackJSON string) (*Slack, error) {
+	if slackJSON == "" {
+		return nil, nil
+	}
+
+	var slack Slack
+	if err := json.Unmarshal([]byte(slackJSON), &slack); err != nil {
+		return nil, err
+	}
+
+	slack.batchWindow = defaultSlackBatchWindow
+	if slack.BatchWindow != "" {
+		batchWindow, err := time.ParseDuration(slack.BatchWindow)
+		if err != nil {
+			return nil, fmt.Errorf("slack batch_window: %s", err.Error())
+		}
+		slack.batchWindow = batchWindow
+	}
+
+	if slack.MaxAttachments < 1 {
+		slack.MaxAttachments = defaultSlackMaxAttachments
+	}
+
+	for _, route := range slack.Routes {
+		if route.WebhookURL == "" && slack.WebhookURL == "" {
+			return nil, errors.New("slack routes need a webhook_url when there is no default webhook_url")
+		}
+	}
+
+	slack.client = &http.Client{Timeout: 10 * time.Second}
+	slack.pending = map[slackDestination][]Transition{}
+	return &slack, nil
+}
+
+func (r SlackRoute) matches(transition Transition) bool {
+	return (r.Group == "" || contains(transition.CSGroups, r.Group)) &&
+		(r.Host == "" || r.Host == transition.Host) &&
+		(r.Team == "" || r.Team == transition.Team) &&
+		(r.Pipeline == "" || r.Pipeline == transition.Pipeline)
+}
+
+// destination returns where a transition is sent, false when it matches no route
+// and there is no default webhook
+func (s *Slack) destination(transition Transition) (slackDestination, bool) {
+	for _, route := range s.Routes {
+		if !route.matches(transition) {
+			continue
+		}
+		destination := slackDestination{webhookURL: route.WebhookURL, channel: route.Channel}
+		if destination.webhookURL == "" {
+			destination.webhookURL = s.WebhookURL
+		}
+		if destination.channel == "" && route.WebhookURL == "" {
+			destination.channel = s.Channel
+		}
+		return destination, true
+	}
+	return slackDestination{webhookURL: s.WebhookURL, channel: s.Channel}, s.WebhookURL != ""
+}
+
+// Notify queues transitions for their destinations, they are sent once the batch
+// window has passed or straight away when there is no window
+func (s *Slack) Notify(transitions []Transition) error {
+	s.mutex.Lock()
+	for _, transition := range transitions {
+		if destination, ok := s.destination(transition); ok {
+			s.pending[destination] = append(s.pending[destination], transition)
+		}
+	}
+
+	if s.batchWindow == 0 {
+		s.mutex.Unlock()
+		return s.Flush()
+	}
+
+	if s.timer == nil && len(s.pending) > 0 {
+		s.timer = time.AfterFunc(s.batchWindow, func() {
+			if err := s.Flush(); err != nil {
+				fmt.Println(err.Error())
+			}
+		})
+	}
+	s.mutex.Unlock()
+	return nil
+}
+
+// Flush sends every queued transition
+func (s *Slack) Flush() error {
+	s.mutex.Lock()
+	pending := s.pending
+	s.pending = map[slackDestination][]Transition{}
+	if s.timer != nil {
+		s.timer.Stop()
+		s.timer = nil
+	}
+	s.mutex.Unlock()
+
+	var failed []string
+	for destination, transitions := range pending {
+		if err := s.post(destination, s.message(destination, transitions)); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return fmt.Errorf("slack: %s", strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// failingJobs returns the names of the jobs in a pipeline group whose latest build
+// didn't succeed
+func failingJobs(datum Data) []string {
+	var names []string
+	for _, job := range datum.Jobs {
+		switch job.Status {
+		case "failed", "errored", "aborted":
+			if !job.Paused && !contains(names, job.Name) {
+				names = append(names, job.Name)
+			}
+		}
+	}
+	return names
+}
+
+func transitionName(transition Transition) string {
+	return ccName(transition.Host, transition.Team, transition.Pipeline, transition.Group)
+}
+
+func slackAttachmentFor(transition Transition) slackAttachment {
+	change := fmt.Sprintf("%s → %s", transition.From, transition.To)
+	text := change
+	if jobs := failingJobs(transition.Data); len(jobs) > 0 {
+		text = fmt.Sprintf("%s\nFailing jobs: %s", change, strings.Join(jobs, ", "))
+	}
+
+	return slackAttachment{
+		Color:     stateColors[transition.To],
+		Fallback:  fmt.Sprintf("%s: %s", transitionName(transition), change),
+		Title:     transitionName(transition),
+		TitleLink: transition.URL,
+		Text:      text,
+		Blocks: []slackBlock{
+			{
+				Type: "section",
+				Text: slackText{Type: "mrkdwn", Text: fmt.Sprintf("*<%s|%s>*\n%s", transition.URL, transitionName(transition), text)},
+			},
+		},
+	}
+}
+
+// message builds one message for a batch of transitions, transitions beyond the
+// attachment limit are summarised by their new state
+func (s *Slack) message(destination slackDestination, transitions []Transition) slackMessage {
+	message := slackMessage{
+		Channel:  destination.channel,
+		Username: s.Username,
+		Text:     fmt.Sprintf("%d pipelines changed state", len(transitions)),
+	}
+	if len(transitions) == 1 {
+		message.Text = fmt.Sprintf("%s is now %s", transitionName(transitions[0]), transitions[0].To)
+	}
+
+	for i, transition := range transitions {
+		if i == s.MaxAttachments {
+			break
+		}
+		message.Attachments = append(message.Attachments, slackAttachmentFor(transition))
+	}
+
+	if len(transitions) > s.MaxAttachments {
+		counts := map[string]int{}
+		for _, transition := range transitions[s.MaxAttachments:] {
+			counts[transition.To]++
+		}
+		var states []string
+		for state, count := range counts {
+			states = append(states, fmt.Sprintf("%d %s", count, state))
+		}
+		sort.Strings(states)
+
+		text := fmt.Sprintf("and %d more: %s", len(transitions)-s.MaxAttachments, strings.Join(states, ", "))
+		message.Attachments = append(message.Attachments, slackAttachment{
+			Color:    stateColors["pending"],
+			Fallback: text,
+			Text:     text,
+			Blocks:   []slackBlock{{Type: "section", Text: slackText{Type: "mrkdwn", Text: text}}},
+		})
+	}
+	return message
+}
+
+func (s *Slack) post(destination slackDestination, message slackMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+
+	// webhook URLs are secret so only the channel is reported
+	response, err := s.client.Post(destination.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s posting to channel %q", err.Error(), destination.channel)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected response %s posting to channel %q", response.Status, destination.channel)
+	}
+	return nil
+}
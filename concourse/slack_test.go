@@ -0,0 +1,223 @@
+package summary_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+type slackMessage struct {
+	Path        string
+	Channel     string `json:"channel"`
+	Username    string `json:"username"`
+	Text        string `json:"text"`
+	Attachments []struct {
+		Color     string `json:"color"`
+		Fallback  string `json:"fallback"`
+		Title     string `json:"title"`
+		TitleLink string `json:"title_link"`
+		Text      string `json:"text"`
+		Blocks    []struct {
+			Type string `json:"type"`
+			Text struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"text"`
+		} `json:"blocks"`
+	} `json:"attachments"`
+}
+
+type slackReceiver struct {
+	mutex    sync.Mutex
+	messages []slackMessage
+}
+
+func (s *slackReceiver) handler(w http.ResponseWriter, r *http.Request) {
+	defer GinkgoRecover()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	message := slackMessage{Path: r.URL.Path}
+	Ω(json.Unmarshal(body, &message)).Should(Succeed())
+	s.messages = append(s.messages, message)
+}
+
+func (s *slackReceiver) Messages() []slackMessage {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.messages
+}
+
+func failedTransition(pipeline string, csGroups ...string) summary.Transition {
+	return summary.Transition{
+		Host:     "ci.example.com",
+		Team:     "main",
+		Pipeline: pipeline,
+		From:     "succeeded",
+		To:       "failed",
+		URL:      "https://ci.example.com/teams/main/pipelines/" + pipeline,
+		CSGroups: csGroups,
+		Data: summary.Data{
+			Jobs: []summary.JobData{
+				{Name: "unit", Status: "failed"},
+				{Name: "lint", Status: "succeeded"},
+				{Name: "deploy", Status: "errored"},
+				{Name: "smoke", Status: "failed", Paused: true},
+			},
+		},
+	}
+}
+
+var _ = Describe("Slack", func() {
+	var (
+		receiver    *slackReceiver
+		slackServer *httptest.Server
+		slack       *summary.Slack
+		slackJSON   string
+		err         error
+	)
+
+	BeforeEach(func() {
+		receiver = &slackReceiver{}
+		slackServer = httptest.NewServer(http.HandlerFunc(receiver.handler))
+		slackJSON = fmt.Sprintf(`{"webhook_url": "%s/default", "channel": "#ci", "username": "concourse", "batch_window": "0s"}`, slackServer.URL)
+	})
+
+	AfterEach(func() {
+		slackServer.Close()
+	})
+
+	JustBeforeEach(func() {
+		slack, err = summary.SetupSlack(slackJSON)
+		Ω(err).Should(BeNil())
+	})
+
+	It("posts a colour coded message linking to the pipeline with its failing jobs", func() {
+		Ω(slack.Notify([]summary.Transition{failedTransition("deploy")})).Should(Succeed())
+
+		messages := receiver.Messages()
+		Ω(messages).Should(HaveLen(1))
+		Ω(messages[0].Path).Should(Equal("/default"))
+		Ω(messages[0].Channel).Should(Equal("#ci"))
+		Ω(messages[0].Username).Should(Equal("concourse"))
+		Ω(messages[0].Text).Should(Equal("ci.example.com/main/deploy is now failed"))
+
+		Ω(messages[0].Attachments).Should(HaveLen(1))
+		attachment := messages[0].Attachments[0]
+		Ω(attachment.Color).Should(Equal("#E74C3C"))
+		Ω(attachment.Title).Should(Equal("ci.example.com/main/deploy"))
+		Ω(attachment.TitleLink).Should(Equal("https://ci.example.com/teams/main/pipelines/deploy"))
+		Ω(attachment.Text).Should(Equal("succeeded → failed\nFailing jobs: unit, deploy"))
+		Ω(attachment.Blocks).Should(HaveLen(1))
+		Ω(attachment.Blocks[0].Text.Type).Should(Equal("mrkdwn"))
+		Ω(attachment.Blocks[0].Text.Text).Should(Equal("*<https://ci.example.com/teams/main/pipelines/deploy|ci.example.com/main/deploy>*\nsucceeded → failed\nFailing jobs: unit, deploy"))
+	})
+
+	Context("when there are more transitions than attachments", func() {
+		BeforeEach(func() {
+			slackJSON = fmt.Sprintf(`{"webhook_url": "%s/default", "batch_window": "0s", "max_attachments": 2}`, slackServer.URL)
+		})
+
+		It("summarises the rest in a single message", func() {
+			recovered := failedTransition("release")
+			recovered.From, recovered.To = "failed", "succeeded"
+			Ω(slack.Notify([]summary.Transition{
+				failedTransition("a"), failedTransition("b"), failedTransition("c"), failedTransition("d"), recovered,
+			})).Should(Succeed())
+
+			messages := receiver.Messages()
+			Ω(messages).Should(HaveLen(1))
+			Ω(messages[0].Text).Should(Equal("5 pipelines changed state"))
+			Ω(messages[0].Attachments).Should(HaveLen(3))
+			Ω(messages[0].Attachments[2].Text).Should(Equal("and 3 more: 1 succeeded, 2 failed"))
+		})
+	})
+
+	Context("when routes are configured", func() {
+		BeforeEach(func() {
+			slackJSON = fmt.Sprintf(`{
+				"webhook_url": "%[1]s/default",
+				"channel": "#ci",
+				"batch_window": "0s",
+				"routes": [
+					{"pipeline": "release", "channel": "#releases"},
+					{"group": "platform", "webhook_url": "%[1]s/platform"}
+				]
+			}`, slackServer.URL)
+		})
+
+		It("sends each transition to the first route it matches", func() {
+			Ω(slack.Notify([]summary.Transition{
+				failedTransition("release", "platform"),
+				failedTransition("deploy", "platform"),
+				failedTransition("other", "apps"),
+			})).Should(Succeed())
+
+			messages := map[string]slackMessage{}
+			for _, message := range receiver.Messages() {
+				messages[message.Path+message.Channel] = message
+			}
+			Ω(messages).Should(HaveLen(3))
+			Ω(messages["/default#releases"].Attachments[0].Title).Should(Equal("ci.example.com/main/release"))
+			Ω(messages["/platform"].Attachments[0].Title).Should(Equal("ci.example.com/main/deploy"))
+			Ω(messages["/default#ci"].Attachments[0].Title).Should(Equal("ci.example.com/main/other"))
+		})
+	})
+
+	Context("when transitions are batched", func() {
+		BeforeEach(func() {
+			slackJSON = fmt.Sprintf(`{"webhook_url": "%s/default", "batch_window": "100ms"}`, slackServer.URL)
+		})
+
+		It("sends the transitions of every notification in the window together", func() {
+			Ω(slack.Notify([]summary.Transition{failedTransition("a")})).Should(Succeed())
+			Ω(slack.Notify([]summary.Transition{failedTransition("b"), failedTransition("c")})).Should(Succeed())
+			Ω(receiver.Messages()).Should(BeEmpty())
+
+			Eventually(receiver.Messages).Should(HaveLen(1))
+			Ω(receiver.Messages()[0].Attachments).Should(HaveLen(3))
+			Consistently(receiver.Messages, "200ms").Should(HaveLen(1))
+		})
+	})
+
+	Context("when the webhook can't be reached", func() {
+		BeforeEach(func() {
+			slackJSON = `{"webhook_url": "http://127.0.0.1:1/services/secret-token", "channel": "#ci", "batch_window": "0s"}`
+		})
+
+		It("reports the channel without the secret webhook url", func() {
+			err := slack.Notify([]summary.Transition{failedTransition("deploy")})
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(`posting to channel "#ci"`))
+			Ω(err.Error()).ShouldNot(ContainSubstring("secret-token"))
+		})
+	})
+
+	Describe("SetupSlack", func() {
+		It("returns nil when slack isn't configured", func() {
+			slack, err := summary.SetupSlack("")
+			Ω(err).Should(BeNil())
+			Ω(slack).Should(BeNil())
+		})
+
+		It("rejects an invalid batch window", func() {
+			_, err := summary.SetupSlack(`{"webhook_url": "https://hooks.example.com", "batch_window": "soon"}`)
+			Ω(err).Should(MatchError(ContainSubstring("slack batch_window")))
+		})
+
+		It("rejects routes without anywhere to send to", func() {
+			_, err := summary.SetupSlack(`{"routes": [{"group": "platform", "channel": "#platform"}]}`)
+			Ω(err).Should(MatchError("slack routes need a webhook_url when there is no default webhook_url"))
+		})
+	})
+})
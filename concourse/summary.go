@@ -6,12 +6,18 @@ import (
 	"html/template"
 	"net/http"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 )
 
-var defaultRefreshInterval = 30
+var (
+	defaultRefreshInterval  = 30
+	defaultFetchConcurrency = 4
+	defaultTeam             = "main"
+	allTeams                = "*"
+)
 
 type indexStruct struct {
 	Hosts  []Host
@@ -27,27 +33,72 @@ type Config struct {
 	Templates         *template.Template
 	Protocol          string
 	Team              string
+	FetchConcurrency  int
+	Credentials       map[string]Credentials
+	Cache             *Cache
+	History           *History
+
+	storedGroups      int
+	caches            *configCaches
+}
+
+// configCaches are the http clients and report builds kept by a config, they are
+// shared by the copies of a config made when its stored groups change
+type configCaches struct {
+	httpClients       map[string]*http.Client
+	httpClientsMutex  sync.Mutex
+	reportBuilds      map[string]cachedBuilds
+	reportBuildsMutex sync.Mutex
+}
+
+// configCachesMutex guards creating the caches of configs, which are created on
+// first use so that a config can be declared without them
+var configCachesMutex sync.Mutex
+
+func (config *Config) sharedCaches() *configCaches {
+	configCachesMutex.Lock()
+	defer configCachesMutex.Unlock()
+
+	if config.caches == nil {
+		config.caches = &configCaches{}
+	}
+	return config.caches
 }
 
 // CSGroups is a collection of concourse summary groups
 type CSGroups []CSGroup
 
-// CSGroup is a concourse summary group
+// CSGroup is a concourse summary group, showing the pipelines of its hosts and/or the
+// pipelines picked out by its selectors
 type CSGroup struct {
-	Group string `json:"group"`
-	Hosts []Host `json:"hosts"`
+	Group  string     `json:"group"`
+	Hosts  []Host     `json:"hosts"`
+	Select []Selector `json:"select,omitempty"`
 }
 
-// Host is a concourse host defined within a concourse summary group
+// Host is a concourse host, either configured in HOSTS with its connection settings
+// or defined within a concourse summary group with the pipelines to show
 type Host struct {
-	FQDN      string     `json:"fqdn"`
-	Pipelines []Pipeline `json:"pipelines"`
+	FQDN             string       `json:"fqdn"`
+	Protocol         string       `json:"protocol,omitempty"`
+	Port             int          `json:"port,omitempty"`
+	Team             string       `json:"team,omitempty"`
+	Teams            []string     `json:"teams,omitempty"`
+	TLS              *HostTLS     `json:"tls,omitempty"`
+	Credentials      *Credentials `json:"credentials,omitempty"`
+	FetchConcurrency int          `json:"fetch_concurrency,omitempty"`
+	Pipelines        []Pipeline   `json:"pipelines"`
 }
 
-// Pipeline is a pipeline definted within a concourse summary group host
+// Pipeline is a pipeline definted within a concourse summary group host. Names and
+// groups are patterns, see matchPattern, and pipelines or groups matching an exclude
+// pattern are left out
 type Pipeline struct {
-	Name   string   `json:"name"`
-	Groups []string `json:"groups"`
+	Team          string   `json:"team,omitempty"`
+	Name          string   `json:"name"`
+	Groups        []string `json:"groups"`
+	Exclude       []string `json:"exclude,omitempty"`
+	ExcludeGroups []string `json:"exclude_groups,omitempty"`
 }
 
 type headerStruct struct {
@@ -72,11 +123,16 @@ type singleHostStruct struct {
 	Statuses []Data
 }
 
+func (s singleHostStruct) Tiles() []tile {
+	return tiles(s.Statuses)
+}
+
 // SetupConfig sets up a config object for summary, adding default values where appropriate
-func SetupConfig(refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString, teamName string) (*Config, error) {
+func SetupConfig(refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString, teamName, fetchConcurrency, credentialsJSON string) (*Config, error) {
 	var (
-		refreshIntervalInt int
-		err                error
+		refreshIntervalInt  int
+		fetchConcurrencyInt int
+		err                 error
 	)
 
 	if refreshInterval == "" {
@@ -92,6 +148,19 @@ func SetupConfig(refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString
 		}
 	}
 
+	if fetchConcurrency == "" {
+		fetchConcurrencyInt = defaultFetchConcurrency
+	} else {
+		fetchConcurrencyInt, err = strconv.Atoi(fetchConcurrency)
+		if err != nil {
+			return &Config{}, err
+		}
+
+		if fetchConcurrencyInt < 1 {
+			fetchConcurrencyInt = defaultFetchConcurrency
+		}
+	}
+
 	var groups CSGroups
 
 	if groupsJSON == "" {
@@ -102,20 +171,25 @@ func SetupConfig(refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString
 		return &Config{}, err
 	}
 
-	var hostsSlice []string
+	var hosts []Host
 
 	if hostsJSON == "" {
 		hostsJSON = "[]"
 	}
 
-	if err := json.Unmarshal([]byte(hostsJSON), &hostsSlice); err != nil {
+	if err := json.Unmarshal([]byte(hostsJSON), &hosts); err != nil {
 		return &Config{}, err
 	}
 
-	var hosts []Host
+	for i := range hosts {
+		if err := hosts[i].validate(); err != nil {
+			return &Config{}, err
+		}
+	}
 
-	for _, host := range hostsSlice {
-		hosts = append(hosts, Host{FQDN: host})
+	credentials, err := parseCredentials(credentialsJSON)
+	if err != nil {
+		return &Config{}, err
 	}
 
 	var skipSSLValidation bool
@@ -124,17 +198,85 @@ func SetupConfig(refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString
 	}
 
 	if teamName == "" {
-		teamName = "main"
+		teamName = defaultTeam
 	}
 
-	return &Config{
+	config := &Config{
 		RefreshInterval:   refreshIntervalInt,
 		CSGroups:          groups,
 		Hosts:             hosts,
 		SkipSSLValidation: skipSSLValidation,
 		Protocol:          "https",
 		Team:              teamName,
-	}, nil
+		FetchConcurrency:  fetchConcurrencyInt,
+		Credentials:       credentials,
+	}
+
+	// snapshots are kept for two refresh intervals so a slow poll doesn't force
+	// page requests to fetch from concourse themselves
+	config.Cache = NewCache(2*time.Duration(refreshIntervalInt)*time.Second, config.fetch)
+
+	return config, nil
+}
+
+// fetch collects the data of a host from concourse, using the history to say how
+// long each pipeline group has been in its state when there is one
+func (config *Config) fetch(host string) ([]Data, error) {
+	data, err := getData(config.host(host), config)
+	if err != nil || config.History == nil {
+		return data, err
+	}
+	// recorded here rather than from the cache so no fetch is missed
+	now := time.Now()
+	if err := config.History.Annotate(data, now); err != nil {
+		fmt.Println(err.Error())
+	}
+	if err := config.History.Record(Snapshot{Host: host, Data: data, FetchedAt: now}); err != nil {
+		fmt.Println(err.Error())
+	}
+	return data, nil
+}
+
+// hostSnapshot returns the cached snapshot of a polled host, other hosts are fetched
+// every time so a url naming any host can't add to the cache
+func (config *Config) hostSnapshot(host string) Snapshot {
+	if config.Cache == nil || !config.polls(host) {
+		data, err := config.fetch(host)
+		now := time.Now()
+		snapshot := Snapshot{Host: host, Data: data, AttemptedAt: now, Err: err}
+		if err == nil {
+			snapshot.FetchedAt = now
+		}
+		return snapshot
+	}
+	return config.Cache.Get(host)
+}
+
+// groupData collects the data for every host in a group in parallel, hosts which
+// fail are returned with an error rather than failing the whole group
+func (config *Config) groupData(csGroup CSGroup) []GroupData {
+	hosts := config.groupHosts(csGroup)
+	groupsData := make([]GroupData, len(hosts))
+
+	var waitGroup sync.WaitGroup
+	for i, host := range hosts {
+		waitGroup.Add(1)
+		go func(i int, host Host) {
+			defer waitGroup.Done()
+
+			snapshot := config.hostSnapshot(host.FQDN)
+			groupsData[i] = GroupData{Host: host.FQDN, LastFetched: snapshot.FetchedAt, LastAttempted: snapshot.AttemptedAt}
+			if snapshot.Err != nil {
+				groupsData[i].Error = errorClass(snapshot.Err)
+				fmt.Println(snapshot.Err.Error())
+				return
+			}
+			groupsData[i].Statuses = csGroup.selected(filterData(snapshot.Data, host.Pipelines))
+		}(i, host)
+	}
+	waitGroup.Wait()
+
+	return groupsData
 }
 
 // Index renders and serves the index page
@@ -149,20 +291,20 @@ func (config *Config) Index(w http.ResponseWriter, r *http.Request) {
 func (config *Config) HostSummary(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	host := vars["host"]
-	values, err := getData(host, config)
-	if err != nil {
+	snapshot := config.hostSnapshot(host)
+	if snapshot.Err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
 		fmt.Fprintf(w, "Error collecting data from concourse (%s) please refer to logs for more details", host)
-		fmt.Println(err.Error())
+		fmt.Println(snapshot.Err.Error())
 		return
 	}
 
-	err = config.Templates.ExecuteTemplate(w, "host", hostStruct{
+	err := config.Templates.ExecuteTemplate(w, "host", hostStruct{
 		Header: headerStruct{
 			RefreshInterval: config.RefreshInterval,
 		},
 		SingleHost: singleHostStruct{
-			Statuses: values,
+			Statuses: snapshot.Data,
 		},
 	})
 	if err != nil {
@@ -176,23 +318,11 @@ func (config *Config) GroupSummary(w http.ResponseWriter, r *http.Request) {
 	group := vars["group"]
 	csGroup := config.CSGroups.group(group)
 
-	var groupsData []GroupData
-	for _, host := range csGroup.Hosts {
-		values, err := getData(host.FQDN, config)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			fmt.Fprintf(w, "Error collecting data from concourse (%s) please refer to logs for more details", host.FQDN)
-			fmt.Println(err.Error())
-			return
-		}
-		groupsData = append(groupsData, GroupData{Host: host.FQDN, Statuses: filterData(values, host.Pipelines)})
-	}
-
 	err := config.Templates.ExecuteTemplate(w, "group", groupStruct{
 		Header: headerStruct{
 			RefreshInterval: config.RefreshInterval,
 		},
-		Groups: groupsData,
+		Groups: config.groupData(csGroup),
 	})
 
 	if err != nil {
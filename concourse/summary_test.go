@@ -3,9 +3,12 @@ package summary_test
 import (
 	"fmt"
 	"html/template"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"regexp"
+	"time"
 	"unicode"
 
 	. "github.com/onsi/ginkgo"
@@ -47,13 +50,13 @@ func stripHostPort(in string) (out string) {
 
 var _ = Describe("#SetupConfig", func() {
 	var (
-		config                                                                    *summary.Config
-		err                                                                       error
-		refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString, teamName string
+		config                                                                                                       *summary.Config
+		err                                                                                                          error
+		refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString, teamName, fetchConcurrency, credentialsJSON string
 	)
 
 	JustBeforeEach(func() {
-		config, err = summary.SetupConfig(refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString, teamName)
+		config, err = summary.SetupConfig(refreshInterval, groupsJSON, hostsJSON, skipSSLValidationString, teamName, fetchConcurrency, credentialsJSON)
 	})
 
 	AfterEach(func() {
@@ -62,6 +65,8 @@ var _ = Describe("#SetupConfig", func() {
 		hostsJSON = ""
 		skipSSLValidationString = ""
 		teamName = ""
+		fetchConcurrency = ""
+		credentialsJSON = ""
 	})
 
 	Context("when refreshInterval is blank", func() {
@@ -70,6 +75,12 @@ var _ = Describe("#SetupConfig", func() {
 			Ω(config.RefreshInterval).Should(Equal(30))
 			Ω(config.Protocol).Should(Equal("https"))
 		})
+
+		It("returns populated config with a cache kept for two refresh intervals", func() {
+			Ω(err).Should(BeNil())
+			Ω(config.Cache).ShouldNot(BeNil())
+			Ω(config.Cache.MaxAge).Should(Equal(60 * time.Second))
+		})
 	})
 
 	Context("when refreshInterval is not blank", func() {
@@ -187,6 +198,63 @@ var _ = Describe("#SetupConfig", func() {
 				Ω(config.Protocol).Should(Equal("https"))
 			})
 		})
+
+		Context("and the JSON contains host objects", func() {
+			BeforeEach(func() {
+				hostsJSON = `["host1", {"fqdn": "host2", "protocol": "http", "port": 8080, "team": "dev", "tls": {"skip_ssl_validation": true}, "credentials": {"token": "abc"}, "fetch_concurrency": 2}]`
+			})
+
+			It("returns populated config with the Hosts and their settings", func() {
+				Ω(err).Should(BeNil())
+				Ω(config.Hosts).Should(Equal([]summary.Host{
+					{
+						FQDN: "host1",
+					},
+					{
+						FQDN:             "host2",
+						Protocol:         "http",
+						Port:             8080,
+						Team:             "dev",
+						TLS:              &summary.HostTLS{SkipSSLValidation: true},
+						Credentials:      &summary.Credentials{Token: "abc"},
+						FetchConcurrency: 2,
+					},
+				}))
+			})
+		})
+
+		Context("and a host object has no fqdn", func() {
+			BeforeEach(func() {
+				hostsJSON = `[{"protocol": "http"}]`
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(MatchError("host fqdn is required"))
+				Ω(config).Should(Equal(&summary.Config{}))
+			})
+		})
+
+		Context("and a host object has an invalid protocol", func() {
+			BeforeEach(func() {
+				hostsJSON = `[{"fqdn": "host1", "protocol": "ftp"}]`
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(MatchError(`host host1: protocol must be http or https, got "ftp"`))
+				Ω(config).Should(Equal(&summary.Config{}))
+			})
+		})
+
+		Context("and a host object has a missing CA certificate", func() {
+			BeforeEach(func() {
+				hostsJSON = `[{"fqdn": "host1", "tls": {"ca_cert_file": "/does/not/exist"}}]`
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(MatchError("host host1: open /does/not/exist: no such file or directory"))
+				Ω(config).Should(Equal(&summary.Config{}))
+			})
+		})
 	})
 
 	Context("when skipSSLValidationString is blank", func() {
@@ -221,6 +289,114 @@ var _ = Describe("#SetupConfig", func() {
 			})
 		})
 	})
+	Context("when fetchConcurrency is blank", func() {
+		It("returns populated config with the default fetch concurrency", func() {
+			Ω(err).Should(BeNil())
+			Ω(config.FetchConcurrency).Should(Equal(4))
+		})
+	})
+
+	Context("when fetchConcurrency is not blank", func() {
+		Context("and fetchConcurrency cannot be converted to an int", func() {
+			BeforeEach(func() {
+				fetchConcurrency = "notANumber"
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(MatchError(`strconv.Atoi: parsing "notANumber": invalid syntax`))
+				Ω(config).Should(Equal(&summary.Config{}))
+			})
+		})
+
+		Context("and fetchConcurrency is less than 1", func() {
+			BeforeEach(func() {
+				fetchConcurrency = "0"
+			})
+
+			It("returns populated config with the default fetch concurrency", func() {
+				Ω(err).Should(BeNil())
+				Ω(config.FetchConcurrency).Should(Equal(4))
+			})
+		})
+
+		Context("and fetchConcurrency is greater than or equal to 1", func() {
+			BeforeEach(func() {
+				fetchConcurrency = "10"
+			})
+
+			It("returns populated config with the provided fetch concurrency", func() {
+				Ω(err).Should(BeNil())
+				Ω(config.FetchConcurrency).Should(Equal(10))
+			})
+		})
+	})
+	Context("when credentialsJSON is blank", func() {
+		It("returns populated config with no credentials", func() {
+			Ω(err).Should(BeNil())
+			Ω(config.Credentials).Should(BeEmpty())
+		})
+	})
+
+	Context("when credentialsJSON is not blank", func() {
+		Context("and the JSON is invalid", func() {
+			BeforeEach(func() {
+				credentialsJSON = "{]"
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(MatchError(`invalid character ']' looking for beginning of object key string`))
+				Ω(config).Should(Equal(&summary.Config{}))
+			})
+		})
+
+		Context("and a host has both a token and a username", func() {
+			BeforeEach(func() {
+				credentialsJSON = `{"host1": {"token": "abc", "username": "admin"}}`
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(MatchError("credentials for host1: credentials must have either a token or a username, not both"))
+				Ω(config).Should(Equal(&summary.Config{}))
+			})
+		})
+
+		Context("and a password file does not exist", func() {
+			BeforeEach(func() {
+				credentialsJSON = `{"host1": {"username": "admin", "password_file": "/does/not/exist"}}`
+			})
+
+			It("returns an error", func() {
+				Ω(err).Should(MatchError("credentials for host1: open /does/not/exist: no such file or directory"))
+				Ω(config).Should(Equal(&summary.Config{}))
+			})
+		})
+
+		Context("and the JSON is valid", func() {
+			var passwordFile string
+
+			BeforeEach(func() {
+				file, fileErr := ioutil.TempFile("", "password")
+				Ω(fileErr).Should(BeNil())
+				fmt.Fprintln(file, "secret")
+				file.Close()
+				passwordFile = file.Name()
+
+				credentialsJSON = fmt.Sprintf(`{"host1": {"token": "abc"}, "host2": {"username": "admin", "password_file": "%s"}}`, passwordFile)
+			})
+
+			AfterEach(func() {
+				os.Remove(passwordFile)
+			})
+
+			It("returns populated config with the credentials read from files", func() {
+				Ω(err).Should(BeNil())
+				Ω(config.Credentials).Should(Equal(map[string]summary.Credentials{
+					"host1": {Token: "abc"},
+					"host2": {Username: "admin", Password: "secret", PasswordFile: passwordFile},
+				}))
+			})
+		})
+	})
 })
 
 var _ = Describe("config#Index", func() {
@@ -509,6 +685,7 @@ var _ = Describe("#HostSummary", func() {
 			mocks := []MockRoute{
 				{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
 				{"GET", "/api/v1/teams/pipelines/test1/jobs", jobsPayload, 200, "", nil},
+				{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
 			}
 			setupMultiple(mocks)
 		})
@@ -537,7 +714,7 @@ var _ = Describe("#HostSummary", func() {
 <div class="scalable">
 
 
-	<a href="http://127.0.0.1:49898/test1.url" target="_blank" class="outer">
+	<a href="/host/127.0.0.1:49898/pipeline/test1" class="outer" data-key="127.0.0.1:49898::test1:">
 	<div class="status">
 		<div class="paused_job" style="width: 0%;"></div>
 		<div class="aborted" style="width: 16%;"></div>
@@ -560,12 +737,36 @@ var _ = Describe("#HostSummary", func() {
 </html>`)))))
 		})
 	})
+
+	Context("and concourse has pipelines with paused jobs", func() {
+		BeforeEach(func() {
+			mocks := []MockRoute{
+				{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+				{"GET", "/api/v1/teams/pipelines/test1/jobs", pausedJobsPayload, 200, "", nil},
+				{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
+			}
+			setupMultiple(mocks)
+		})
+
+		It("counts paused jobs as paused rather than by their last build", func() {
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(stringMinifier(mockRecorder.Body.String())).Should(ContainSubstring(stringMinifier(`
+	<div class="status">
+		<div class="paused_job" style="width: 50%;"></div>
+		<div class="aborted" style="width: 0%;"></div>
+		<div class="errored" style="width: 0%;"></div>
+		<div class="failed" style="width: 25%;"></div>
+		<div class="succeeded" style="width: 25%;"></div>
+	</div>`)))
+		})
+	})
 })
 
 var _ = Describe("#GroupSummary", func() {
 	var (
 		templates    = template.Must(template.ParseGlob("../templates/*"))
 		mockRecorder *httptest.ResponseRecorder
+		extraHosts   []summary.Host
 		config       = &summary.Config{
 			Templates: templates,
 			Protocol:  "http",
@@ -581,6 +782,7 @@ var _ = Describe("#GroupSummary", func() {
 			Templates: templates,
 			Protocol:  "http",
 		}
+		extraHosts = nil
 	})
 
 	JustBeforeEach(func() {
@@ -589,11 +791,11 @@ var _ = Describe("#GroupSummary", func() {
 		config.CSGroups = []summary.CSGroup{
 			{
 				Group: "test",
-				Hosts: []summary.Host{
+				Hosts: append([]summary.Host{
 					{
 						FQDN: Host(server),
 					},
-				},
+				}, extraHosts...),
 			},
 		}
 
@@ -609,9 +811,75 @@ var _ = Describe("#GroupSummary", func() {
 			setupMultiple(mocks)
 		})
 
-		It("returns an error", func() {
-			Ω(mockRecorder.Code).Should(Equal(500))
-			Ω(mockRecorder.Body.String()).Should(MatchRegexp(`Error collecting data from concourse \(127.0.0.1:\d{1,6}\) please refer to logs for more details`))
+		It("returns a page with an error tile for the host", func() {
+			Ω(mockRecorder.Code).Should(Equal(200))
+			Ω(stripDate(stripHostPort(stringMinifier(mockRecorder.Body.String())))).Should(Equal(stripHostPort(stripDate(stringMinifier(`
+<!DOCTYPE html>
+<html>
+  <head rel="v2">
+    <title>Concourse Summary</title>
+    <link rel="icon" type="image/png" href="/favicon.png" sizes="32x32">
+    <link rel="stylesheet" type="text/css" href="/styles.css">
+    <script>window.refresh_interval =  0 </script>
+    <script src="/favico-0.3.10.min.js"></script>
+    <script src="/refresh.js"></script>
+  </head>
+  <body>
+    <div class="time">
+      2017-09-13 09:38:03 &#43;0100 (<span id="countdown">0</span>)
+      <div class="right">
+        <a class="github" href="https://github.com/FidelityInternational/go-concourse-summary" target="_blank">&nbsp;</a>
+      </div>
+    </div>
+
+
+<div class="group">
+  <a href="/host/127.0.0.1:53553">127.0.0.1:53553</a>
+  <div>
+    <a href="/host/127.0.0.1:53553" class="outer host_error" data-key="127.0.0.1:53553:error">
+    <div class="inner">
+      <span class="127.0.0.1:53553"><span>127.0.0.1:53553</span></span>
+      <span class="error"><span>invalid response</span></span>
+      <span class="last_fetched"><span>last fetched: never</span></span>
+    </div>
+    </a>
+  </div>
+</div>
+
+
+  </body>
+</html>
+				`)))))
+		})
+	})
+
+	Context("when one of the hosts fails", func() {
+		var failingServer *httptest.Server
+
+		BeforeEach(func() {
+			mocks := []MockRoute{
+				{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
+				{"GET", "/api/v1/teams/pipelines/test1/jobs", jobsPayload, 200, "", nil},
+				{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
+			}
+			setupMultiple(mocks)
+
+			failingServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			}))
+			extraHosts = []summary.Host{{FQDN: Host(failingServer)}}
+		})
+
+		AfterEach(func() {
+			failingServer.Close()
+		})
+
+		It("renders the healthy host alongside an error tile for the failing host", func() {
+			Ω(mockRecorder.Code).Should(Equal(200))
+			body := mockRecorder.Body.String()
+			Ω(body).Should(ContainSubstring(`<span class="test1"><span>test1</span></span>`))
+			Ω(body).Should(ContainSubstring(fmt.Sprintf(`<a href="/host/%s" class="outer host_error" data-key="%s:error">`, Host(failingServer), Host(failingServer))))
+			Ω(body).Should(ContainSubstring(`<span class="error"><span>unexpected response</span></span>`))
 		})
 	})
 
@@ -666,6 +934,7 @@ var _ = Describe("#GroupSummary", func() {
 			mocks := []MockRoute{
 				{"GET", "/api/v1/teams/pipelines", pipelinesPayload, 200, "", nil},
 				{"GET", "/api/v1/teams/pipelines/test1/jobs", jobsPayload, 200, "", nil},
+				{"GET", "/api/v1/teams/pipelines/test1/resources", "[]", 200, "", nil},
 			}
 			setupMultiple(mocks)
 		})
@@ -697,7 +966,7 @@ var _ = Describe("#GroupSummary", func() {
   <div>
 
 
-  <a href="http://127.0.0.1:53555/test1.url" target="_blank" class="outer">
+  <a href="/host/127.0.0.1:53555/pipeline/test1" class="outer" data-key="127.0.0.1:53555::test1:">
   <div class="status">
     <div class="paused_job" style="width: 0%;"></div>
     <div class="aborted" style="width: 16%;"></div>
@@ -0,0 +1,413 @@
+package summary
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigError is a problem with one setting of a config, located by the file it was
+// read from, if any, and its path within the setting
+type ConfigError struct {
+	File    string
+	Path    string
+	Message string
+}
+
+func (e ConfigError) Error() string {
+	var location []string
+	for _, part := range []string{e.File, e.Path} {
+		if part != "" {
+			location = append(location, part)
+		}
+	}
+	return strings.Join(append(location, e.Message), ": ")
+}
+
+// ConfigErrors are every problem found with a config, so they can all be fixed at
+// once
+type ConfigErrors []ConfigError
+
+func (e ConfigErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// configSetting is the value of a setting in the form SetupConfig takes, along with
+// where it came from. path is the setting's key in the config file or the
+// environment variable which set it
+type configSetting struct {
+	key   string
+	file  string
+	path  string
+	value string
+}
+
+func (s configSetting) error(path, format string, args ...interface{}) ConfigError {
+	return ConfigError{File: s.file, Path: s.path + path, Message: fmt.Sprintf(format, args...)}
+}
+
+// unknownSettings reports the keys of a config file which aren't settings
+func unknownSettings(file string, settings map[string]json.RawMessage) ConfigErrors {
+	known := map[string]bool{}
+	for _, setting := range configSettings {
+		known[setting.key] = true
+	}
+
+	var errs ConfigErrors
+	for _, key := range sortedKeys(settings) {
+		if !known[key] {
+			errs = append(errs, ConfigError{File: file, Path: key, Message: "unknown setting"})
+		}
+	}
+	return errs
+}
+
+// validateSettings checks each setting before SetupConfig parses them, so that a
+// mistake is reported with where it was made rather than ignored or reported as a
+// bare parse error
+func validateSettings(settings []configSetting) ConfigErrors {
+	var (
+		errs                        ConfigErrors
+		hosts                       []Host
+		groups                      CSGroups
+		hostsSetting, groupsSetting configSetting
+	)
+
+	for _, setting := range settings {
+		if setting.value == "" {
+			continue
+		}
+
+		switch setting.key {
+		case "refresh_interval", "fetch_concurrency":
+			value, err := strconv.Atoi(setting.value)
+			if err != nil {
+				errs = append(errs, setting.error("", "must be a whole number, got %q", setting.value))
+			} else if value < 1 {
+				// values below 1 have always fallen back to the default, so they only warn
+				defaultValue := defaultRefreshInterval
+				if setting.key == "fetch_concurrency" {
+					defaultValue = defaultFetchConcurrency
+				}
+				fmt.Println("warning: " + setting.error("", "%d is less than 1, using the default of %d", value, defaultValue).Error())
+			}
+		case "skip_ssl_validation":
+			if setting.value != "true" && setting.value != "false" {
+				errs = append(errs, setting.error("", "must be true or false, got %q", setting.value))
+			}
+		case "hosts":
+			hostsSetting = setting
+			settingErrs, decoded := decodeSetting(setting, &hosts)
+			errs = append(errs, settingErrs...)
+			if decoded {
+				errs = append(errs, validateHosts(setting, hosts)...)
+			}
+		case "groups":
+			groupsSetting = setting
+			settingErrs, decoded := decodeSetting(setting, &groups)
+			errs = append(errs, settingErrs...)
+			if decoded {
+				errs = append(errs, validateGroups(setting, groups)...)
+			}
+		case "credentials":
+			credentials := map[string]Credentials{}
+			settingErrs, decoded := decodeSetting(setting, &credentials)
+			errs = append(errs, settingErrs...)
+			if !decoded {
+				continue
+			}
+			for _, host := range sortedKeys(credentials) {
+				if _, err := credentials[host].resolve(); err != nil {
+					errs = append(errs, setting.error(fmt.Sprintf("[%q]", host), "%s", err.Error()))
+				}
+			}
+		}
+	}
+
+	// hosts in groups only need to be listed in HOSTS when it is used, otherwise
+	// groups bring their own hosts
+	if len(hosts) > 0 {
+		configured := map[string]bool{}
+		for _, host := range hosts {
+			configured[host.FQDN] = true
+		}
+		for i, group := range groups {
+			for j, host := range group.Hosts {
+				if !configured[host.FQDN] {
+					errs = append(errs, groupsSetting.error(fmt.Sprintf("[%d].hosts[%d].fqdn", i, j), "host %q is not in %s", host.FQDN, hostsSetting.path))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+func validateHosts(setting configSetting, hosts []Host) ConfigErrors {
+	var errs ConfigErrors
+	seen := map[string]int{}
+	for i := range hosts {
+		host := hosts[i]
+		if err := host.validate(); err != nil {
+			errs = append(errs, setting.error(fmt.Sprintf("[%d]", i), "%s", err.Error()))
+			continue
+		}
+		if first, ok := seen[host.FQDN]; ok {
+			errs = append(errs, setting.error(fmt.Sprintf("[%d].fqdn", i), "duplicate host %q, first given at %s[%d]", host.FQDN, setting.path, first))
+			continue
+		}
+		seen[host.FQDN] = i
+	}
+	return errs
+}
+
+func validateGroups(setting configSetting, groups CSGroups) ConfigErrors {
+	var errs ConfigErrors
+	seen := map[string]int{}
+	for i, group := range groups {
+		if group.Group == "" {
+			errs = append(errs, setting.error(fmt.Sprintf("[%d].group", i), "group name is required"))
+			continue
+		}
+		if first, ok := seen[group.Group]; ok {
+			errs = append(errs, setting.error(fmt.Sprintf("[%d].group", i), "duplicate group %q, first given at %s[%d]", group.Group, setting.path, first))
+			continue
+		}
+		seen[group.Group] = i
+
+		errs = append(errs, validateGroup(setting, fmt.Sprintf("[%d]", i), group)...)
+	}
+	return errs
+}
+
+// validateGroup checks the hosts, pipelines and selectors of a group
+func validateGroup(setting configSetting, path string, group CSGroup) ConfigErrors {
+	var errs ConfigErrors
+	for j, host := range group.Hosts {
+		if host.FQDN == "" {
+			errs = append(errs, setting.error(fmt.Sprintf("%s.hosts[%d].fqdn", path, j), "host fqdn is required"))
+		}
+		// a group's hosts only choose pipelines, how a host is fetched is set in hosts
+		hostOnly := []struct {
+			name string
+			set  bool
+		}{
+			{"protocol", host.Protocol != ""},
+			{"port", host.Port != 0},
+			{"team", host.Team != ""},
+			{"teams", host.Teams != nil},
+			{"tls", host.TLS != nil},
+			{"credentials", host.Credentials != nil},
+			{"fetch_concurrency", host.FetchConcurrency != 0},
+		}
+		for _, field := range hostOnly {
+			if field.set {
+				errs = append(errs, setting.error(fmt.Sprintf("%s.hosts[%d].%s", path, j, field.name), "only allowed in hosts"))
+			}
+		}
+		for k, pipeline := range host.Pipelines {
+			errs = append(errs, validatePipeline(setting, fmt.Sprintf("%s.hosts[%d].pipelines[%d]", path, j, k), pipeline)...)
+		}
+	}
+	for j, selector := range group.Select {
+		errs = append(errs, validateSelector(setting, fmt.Sprintf("%s.select[%d]", path, j), selector)...)
+	}
+	return errs
+}
+
+// validatePipeline checks the patterns of a pipeline entry
+func validatePipeline(setting configSetting, path string, pipeline Pipeline) ConfigErrors {
+	var errs ConfigErrors
+	if err := validateNamePattern(pipeline.Name); err != nil {
+		errs = append(errs, setting.error(path+".name", "%s", err.Error()))
+	}
+	for i, group := range pipeline.Groups {
+		if err := validateNamePattern(group); err != nil {
+			errs = append(errs, setting.error(fmt.Sprintf("%s.groups[%d]", path, i), "%s", err.Error()))
+		}
+	}
+	errs = append(errs, validatePatterns(setting, path+".exclude", pipeline.Exclude)...)
+	return append(errs, validatePatterns(setting, path+".exclude_groups", pipeline.ExcludeGroups)...)
+}
+
+// validateSelector checks the patterns of a group's selector
+func validateSelector(setting configSetting, path string, selector Selector) ConfigErrors {
+	var errs ConfigErrors
+	lists := []struct {
+		name     string
+		patterns []string
+	}{
+		{"hosts", selector.Hosts},
+		{"teams", selector.Teams},
+		{"names", selector.Names},
+		{"exclude", selector.Exclude},
+		{"groups", selector.Groups},
+		{"exclude_groups", selector.ExcludeGroups},
+	}
+	for _, list := range lists {
+		errs = append(errs, validatePatterns(setting, path+"."+list.name, list.patterns)...)
+	}
+	return errs
+}
+
+// validatePatterns reports the patterns of a list which can't be matched
+func validatePatterns(setting configSetting, path string, patterns []string) ConfigErrors {
+	var errs ConfigErrors
+	for i, pattern := range patterns {
+		if err := validatePattern(pattern); err != nil {
+			errs = append(errs, setting.error(fmt.Sprintf("%s[%d]", path, i), "%s", err.Error()))
+		}
+	}
+	return errs
+}
+
+// decodeSetting parses a JSON setting into v and checks its fields against v's
+// type, so an unknown field or a value of the wrong type is reported with its path.
+// Unknown fields don't stop the setting being decoded, so the rest of it can still be
+// checked
+func decodeSetting(setting configSetting, v interface{}) (ConfigErrors, bool) {
+	var document interface{}
+	if err := json.Unmarshal([]byte(setting.value), &document); err != nil {
+		return ConfigErrors{setting.error("", "%s", jsonError([]byte(setting.value), err).Error())}, false
+	}
+
+	errs := checkFields(setting, "", document, reflect.TypeOf(v).Elem())
+	if err := json.Unmarshal([]byte(setting.value), v); err != nil {
+		if len(errs) == 0 {
+			errs = append(errs, setting.error("", "%s", err.Error()))
+		}
+		return errs, false
+	}
+	return errs, true
+}
+
+var hostType = reflect.TypeOf(Host{})
+
+// checkFields compares a decoded JSON value with the type it is parsed into
+func checkFields(setting configSetting, path string, value interface{}, t reflect.Type) ConfigErrors {
+	if value == nil {
+		return nil
+	}
+
+	wrongType := func(expected string) ConfigErrors {
+		return ConfigErrors{setting.error(path, "must be %s", expected)}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return checkFields(setting, path, value, t.Elem())
+	case reflect.Struct:
+		if _, ok := value.(string); ok && t == hostType {
+			return nil
+		}
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			if t == hostType {
+				return wrongType("an fqdn or an object")
+			}
+			return wrongType("an object")
+		}
+
+		fields := jsonFields(t)
+		var errs ConfigErrors
+		for _, key := range sortedKeys(object) {
+			field, ok := fields[key]
+			if !ok {
+				errs = append(errs, setting.error(joinPath(path, key), "unknown field"))
+				continue
+			}
+			errs = append(errs, checkFields(setting, joinPath(path, key), object[key], field.Type)...)
+		}
+		return errs
+	case reflect.Slice:
+		list, ok := value.([]interface{})
+		if !ok {
+			return wrongType("a list")
+		}
+		var errs ConfigErrors
+		for i, item := range list {
+			errs = append(errs, checkFields(setting, fmt.Sprintf("%s[%d]", path, i), item, t.Elem())...)
+		}
+		return errs
+	case reflect.Map:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return wrongType("an object")
+		}
+		var errs ConfigErrors
+		for _, key := range sortedKeys(object) {
+			errs = append(errs, checkFields(setting, fmt.Sprintf("%s[%q]", path, key), object[key], t.Elem())...)
+		}
+		return errs
+	case reflect.String:
+		if _, ok := value.(string); !ok {
+			return wrongType("a string")
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			return wrongType("true or false")
+		}
+	case reflect.Int:
+		if number, ok := value.(float64); !ok || number != float64(int(number)) {
+			return wrongType("a whole number")
+		}
+	}
+	return nil
+}
+
+// jsonFields are the fields of a struct by their names in JSON
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := map[string]reflect.StructField{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = field
+	}
+	return fields
+}
+
+func joinPath(path, key string) string {
+	return path + "." + key
+}
+
+// jsonError locates a syntax error by its line and column
+func jsonError(contents []byte, err error) error {
+	syntaxErr, ok := err.(*json.SyntaxError)
+	if !ok {
+		return err
+	}
+
+	// the offset is just past the character which couldn't be parsed, or 0 when
+	// there was nothing to parse
+	offset := syntaxErr.Offset - 1
+	if offset < 0 {
+		offset = 0
+	}
+	line, column := 1, 1
+	for _, c := range contents[:offset] {
+		if c == '\n' {
+			line, column = line+1, 1
+		} else {
+			column++
+		}
+	}
+	return fmt.Errorf("line %d, column %d: %s", line, column, syntaxErr.Error())
+}
+
+func sortedKeys(object interface{}) []string {
+	var keys []string
+	for _, key := range reflect.ValueOf(object).MapKeys() {
+		keys = append(keys, key.String())
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,238 @@
+package summary_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/FidelityInternational/go-concourse-summary/concourse"
+)
+
+var _ = Describe("Config validation", func() {
+	var (
+		dir string
+		env map[string]string
+	)
+
+	getenv := func(name string) string {
+		return env[name]
+	}
+
+	// problems returns each problem found loading the config, one per line
+	problems := func(path string) []string {
+		_, err := summary.LoadConfig(path, getenv)
+		Expect(err).To(HaveOccurred())
+		Expect(err).To(BeAssignableToTypeOf(summary.ConfigErrors{}))
+		return strings.Split(err.Error(), "\n")
+	}
+
+	writeConfig := func(contents string) string {
+		path := filepath.Join(dir, "config.yml")
+		Expect(ioutil.WriteFile(path, []byte(contents), 0600)).To(Succeed())
+		return path
+	}
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "validate")
+		Expect(err).ToNot(HaveOccurred())
+		env = map[string]string{}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(dir)
+	})
+
+	It("reports unknown settings and fields with their paths", func() {
+		path := writeConfig(`
+colour: red
+groups:
+  - group: payments
+    hosts:
+      - fqdn: ci.example.com
+        pipelines:
+          - nmae: release
+`)
+		Expect(problems(path)).To(Equal([]string{
+			path + ": colour: unknown setting",
+			path + ": groups[0].hosts[0].pipelines[0].nmae: unknown field",
+		}))
+	})
+
+	It("reports values of the wrong type", func() {
+		path := writeConfig(`
+hosts:
+  - fqdn: ci.example.com
+    port: "8080"
+  - [ci.example.com]
+credentials:
+  ci.example.com: secret
+`)
+		Expect(problems(path)).To(Equal([]string{
+			path + `: hosts[0].port: must be a whole number`,
+			path + `: hosts[1]: must be an fqdn or an object`,
+			path + `: credentials["ci.example.com"]: must be an object`,
+		}))
+	})
+
+	It("locates JSON syntax errors in environment variables by line and column", func() {
+		env["CS_GROUPS"] = `[{"group": "payments",}]`
+		Expect(problems("")).To(Equal([]string{
+			"CS_GROUPS: line 1, column 23: invalid character '}' looking for beginning of object key string",
+		}))
+	})
+
+	It("locates JSON syntax errors in config files by line and column", func() {
+		path := filepath.Join(dir, "config.json")
+		Expect(ioutil.WriteFile(path, []byte("{\n  \"team\": \"main\"\n  \"hosts\": []\n}"), 0600)).To(Succeed())
+		Expect(problems(path)).To(Equal([]string{
+			path + ": line 3, column 3: invalid character '\"' after object key:value pair",
+		}))
+	})
+
+	It("reports empty JSON config files and settings rather than panicking", func() {
+		path := filepath.Join(dir, "config.json")
+		Expect(ioutil.WriteFile(path, nil, 0600)).To(Succeed())
+		Expect(problems(path)).To(Equal([]string{
+			path + ": line 1, column 1: unexpected end of JSON input",
+		}))
+
+		env["CS_GROUPS"] = " "
+		Expect(problems("")).To(Equal([]string{
+			"CS_GROUPS: line 1, column 1: unexpected end of JSON input",
+		}))
+	})
+
+	It("rejects invalid intervals and concurrency", func() {
+		env["REFRESH_INTERVAL"] = "soon"
+		env["FETCH_CONCURRENCY"] = "lots"
+		env["SKIP_SSL_VALIDATION"] = "yes"
+		Expect(problems("")).To(Equal([]string{
+			`REFRESH_INTERVAL: must be a whole number, got "soon"`,
+			`SKIP_SSL_VALIDATION: must be true or false, got "yes"`,
+			`FETCH_CONCURRENCY: must be a whole number, got "lots"`,
+		}))
+	})
+
+	It("defaults intervals and concurrency below 1", func() {
+		env["REFRESH_INTERVAL"] = "0"
+		env["FETCH_CONCURRENCY"] = "-1"
+		config, err := summary.LoadConfig("", getenv)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(config.RefreshInterval).To(Equal(30))
+		Expect(config.FetchConcurrency).To(Equal(4))
+	})
+
+	It("reports duplicate and unnamed groups", func() {
+		path := writeConfig(`
+groups:
+  - group: payments
+  - group: platform
+  - group: payments
+  - hosts: [ci.example.com]
+`)
+		Expect(problems(path)).To(Equal([]string{
+			path + `: groups[2].group: duplicate group "payments", first given at groups[0]`,
+			path + ": groups[3].group: group name is required",
+		}))
+	})
+
+	It("reports hosts in groups which aren't in hosts, when hosts are given", func() {
+		env["HOSTS"] = `["ci.example.com", {"fqdn": "ci.example.com"}]`
+		path := writeConfig(`
+groups:
+  - group: payments
+    hosts: [ci.example.com, ci.exmaple.com]
+`)
+		Expect(problems(path)).To(Equal([]string{
+			`HOSTS[1].fqdn: duplicate host "ci.example.com", first given at HOSTS[0]`,
+			path + `: groups[0].hosts[1].fqdn: host "ci.exmaple.com" is not in HOSTS`,
+		}))
+
+		delete(env, "HOSTS")
+		_, err := summary.LoadConfig(path, getenv)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("reports host settings given in groups, which only hosts can set", func() {
+		path := writeConfig(`
+groups:
+  - group: payments
+    hosts:
+      - fqdn: ci.example.com
+        protocol: http
+        port: 8080
+        teams: [payments]
+        tls: {skip_ssl_validation: true}
+        credentials: {username: admin, password: secret}
+        fetch_concurrency: 2
+`)
+		Expect(problems(path)).To(Equal([]string{
+			path + ": groups[0].hosts[0].protocol: only allowed in hosts",
+			path + ": groups[0].hosts[0].port: only allowed in hosts",
+			path + ": groups[0].hosts[0].teams: only allowed in hosts",
+			path + ": groups[0].hosts[0].tls: only allowed in hosts",
+			path + ": groups[0].hosts[0].credentials: only allowed in hosts",
+			path + ": groups[0].hosts[0].fetch_concurrency: only allowed in hosts",
+		}))
+	})
+
+	It("reports pipeline patterns which can't be matched", func() {
+		path := writeConfig(`
+groups:
+  - group: payments
+    hosts:
+      - fqdn: ci.example.com
+        pipelines:
+          - name: /payments-(/
+            exclude: ["[payments"]
+`)
+		Expect(problems(path)).To(Equal([]string{
+			path + ": groups[0].hosts[0].pipelines[0].name: invalid regular expression /payments-(/: error parsing regexp: missing closing ): `payments-(`",
+			path + `: groups[0].hosts[0].pipelines[0].exclude[0]: invalid pattern "[payments"`,
+		}))
+	})
+
+	It("reports selector patterns which can't be matched", func() {
+		path := writeConfig(`
+groups:
+  - group: paused
+    select:
+      - paused: true
+        names: [release-*, "[release"]
+`)
+		Expect(problems(path)).To(Equal([]string{
+			path + `: groups[0].select[0].names[1]: invalid pattern "[release"`,
+		}))
+
+		path = writeConfig("groups: [{group: public, select: [{public: yes please}]}]")
+		Expect(problems(path)).To(Equal([]string{
+			path + ": groups[0].select[0].public: must be true or false",
+		}))
+	})
+
+	It("accepts names and groups which aren't valid globs as exact names", func() {
+		path := writeConfig(`
+groups:
+  - group: legacy
+    hosts:
+      - fqdn: ci.example.com
+        pipelines:
+          - name: "legacy[2"
+            groups: ["[deploy"]
+`)
+		_, err := summary.LoadConfig(path, getenv)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("reports invalid host settings with the host's path", func() {
+		env["HOSTS"] = `["ci.example.com", {"fqdn": "ftp.example.com", "protocol": "ftp"}]`
+		Expect(problems("")).To(Equal([]string{
+			`HOSTS[1]: host ftp.example.com: protocol must be http or https, got "ftp"`,
+		}))
+	})
+})
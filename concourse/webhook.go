@@ -0,0 +1,115 @@
+package summary
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Webhook posts each transition as JSON to a URL, optionally only for the pipelines
+// shown by some concourse summary groups
+type Webhook struct {
+	URL    string   `json:"url"`
+	Groups []string `json:"groups"`
+
+	client *http.Client
+	host   string
+}
+
+// SetupWebhooks parses the webhooks to notify of transitions
+func SetupWebhooks(webhooksJSON string) ([]*Webhook, error) {
+	var webhooks []*Webhook
+
+	if webhooksJSON == "" {
+		return webhooks, nil
+	}
+
+	if err := json.Unmarshal([]byte(webhooksJSON), &webhooks); err != nil {
+		return nil, err
+	}
+
+	for _, webhook := range webhooks {
+		if err := webhook.validate(); err != nil {
+			return nil, err
+		}
+		webhook.client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return webhooks, nil
+}
+
+// validate checks the webhook's url, webhook URLs often hold a secret so errors only
+// report its scheme and host
+func (w *Webhook) validate() error {
+	if w.URL == "" {
+		return errors.New("webhook url is required")
+	}
+	uri, err := url.Parse(w.URL)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("webhook url is invalid: %s", err.Error())
+	}
+	w.host = uri.Scheme + "://" + uri.Host
+	if uri.Scheme != "http" && uri.Scheme != "https" {
+		return fmt.Errorf("webhook %s: url must be http or https", w.host)
+	}
+	return nil
+}
+
+// inScope reports whether a transition is shown by any of the groups the webhook is
+// limited to, a webhook without groups hears about every transition
+func (w *Webhook) inScope(transition Transition) bool {
+	if len(w.Groups) == 0 {
+		return true
+	}
+	for _, group := range transition.CSGroups {
+		if contains(w.Groups, group) {
+			return true
+		}
+	}
+	return false
+}
+
+// Notify posts every transition in scope, a failed post doesn't stop the others
+// being sent
+func (w *Webhook) Notify(transitions []Transition) error {
+	var failed []string
+	for _, transition := range transitions {
+		if !w.inScope(transition) {
+			continue
+		}
+		if err := w.post(transition); err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("webhook %s: %d notifications failed, last error: %s", w.host, len(failed), failed[len(failed)-1])
+	}
+	return nil
+}
+
+func (w *Webhook) post(transition Transition) error {
+	body, err := json.Marshal(transition)
+	if err != nil {
+		return err
+	}
+
+	response, err := w.client.Post(w.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("unexpected response %s", response.Status)
+	}
+	return nil
+}
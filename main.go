@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"html/template"
 	"log"
@@ -17,20 +18,92 @@ func init() {
 }
 
 func main() {
-	hostsJSON := os.Getenv("HOSTS")
-	groupsJSON := os.Getenv("CS_GROUPS")
-	skipSSLValidationString := os.Getenv("SKIP_SSL_VALIDATION")
-	refreshIntervalString := os.Getenv("REFRESH_INTERVAL")
-	teamName := os.Getenv("TEAM")
-	config, err := summary.SetupConfig(refreshIntervalString, groupsJSON, hostsJSON, skipSSLValidationString, teamName)
+	if len(os.Args) > 1 && os.Args[1] == "check-config" {
+		checkConfig(os.Args[2:])
+		return
+	}
+
+	configPath := flag.String("config", "", "a YAML or JSON config file, reloaded when it changes")
+	flag.Parse()
+
+	config, err := summary.LoadConfig(*configPath, os.Getenv)
 	if err != nil {
 		log.Fatal(err)
 	}
 	config.Templates = templates
 
+	services, err := summary.LoadServices(os.Getenv)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var notifiers []summary.Notifier
+	for _, webhook := range services.Webhooks {
+		notifiers = append(notifiers, webhook)
+	}
+	if services.Slack != nil {
+		notifiers = append(notifiers, services.Slack)
+	}
+	notifications := summary.NewNotifications(config, notifiers...)
+	notifications.Start()
+
+	digest := services.Digest
+	if digest != nil {
+		digest.Config = config
+		digest.Start()
+	}
+
+	history := services.History
+	if history != nil {
+		if err := history.Open(); err != nil {
+			log.Fatal(err)
+		}
+		config.History = history
+		history.Start()
+	}
+
+	poller := summary.NewPoller(config)
+	poller.Start()
+
 	server := summary.CreateServer(config)
+	server.OnReload(poller.Reload, notifications.Reload)
+	if digest != nil {
+		server.OnReload(digest.Reload)
+	}
+	if groupStore := services.GroupStore; groupStore != nil {
+		if err := groupStore.Open(); err != nil {
+			log.Fatal(err)
+		}
+		server.StoreGroups(groupStore)
+	}
+	if *configPath != "" {
+		summary.NewConfigWatcher(*configPath, server).Start()
+	}
 	router := server.Start()
 
 	fmt.Println("listening on :8080")
 	log.Fatal(http.ListenAndServe(":8080", router))
 }
+
+// checkConfig validates the config given by the environment and an optional config
+// file, along with the settings only read from the environment, without starting the
+// summary, exiting non-zero when there are problems
+func checkConfig(args []string) {
+	flags := flag.NewFlagSet("check-config", flag.ExitOnError)
+	configPath := flags.String("config", "", "a YAML or JSON config file")
+	flags.Parse(args)
+
+	valid := true
+	if _, err := summary.LoadConfig(*configPath, os.Getenv); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		valid = false
+	}
+	if _, err := summary.LoadServices(os.Getenv); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		valid = false
+	}
+	if !valid {
+		os.Exit(1)
+	}
+	fmt.Println("config is valid")
+}